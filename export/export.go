@@ -0,0 +1,25 @@
+// Package export writes the result of a transfer in formats other tools can open
+package export
+
+import (
+	"io"
+
+	"github.com/jkulzer/platform-router/models"
+)
+
+// Format is a file format a transfer can be exported as
+type Format struct {
+	Name      string
+	Extension string
+	Write     func(w io.Writer, transfer models.Transfer) error
+}
+
+// Formats are all export formats, in the order they are offered to the user
+var Formats = []Format{
+	{Name: "GeoJSON", Extension: ".geojson", Write: WriteGeoJSON},
+	{Name: "GPX", Extension: ".gpx", Write: WriteGPX},
+	{Name: "KML", Extension: ".kml", Write: WriteKML},
+	{Name: "SVG", Extension: ".svg", Write: WriteSchematicSVG},
+	{Name: "PNG", Extension: ".png", Write: WriteSchematicPNG},
+	{Name: "JSON", Extension: ".json", Write: WriteResult},
+}
@@ -0,0 +1,129 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// the orientation of the spines, which is the same for all of them
+const spineOrientation = "start is where the front of the train stops"
+
+// GeoJSON returns all geometries of a transfer as one feature collection.
+// Every feature has a role property, the features of a platform also have an end property which is either source or dest.
+// The summary of the walk is a foreign member of the collection.
+func GeoJSON(transfer models.Transfer) *geojson.FeatureCollection {
+	collection := geojson.NewFeatureCollection()
+
+	verticalMeans := make([]map[string]any, 0, len(transfer.VerticalMeans))
+	for _, means := range transfer.VerticalMeans {
+		verticalMeans = append(verticalMeans, map[string]any{"kind": means.Kind, "osm_id": means.Feature.String()})
+	}
+	collection.ExtraMembers = geojson.Properties{
+		"summary": map[string]any{
+			"walking_distance": transfer.PathDistance,
+			"walking_time":     transfer.WalkingTime.Seconds(),
+			"level_changes":    transfer.LevelChanges,
+			"vertical_means":   verticalMeans,
+			// an empty list instead of null, so consumers don't have to check
+			"warnings": append([]string{}, transfer.Warnings...),
+		},
+	}
+
+	path := make(orb.LineString, 0, len(transfer.Path))
+	nodeIDs := make([]int64, 0, len(transfer.Path))
+	var levels []string
+	for _, node := range transfer.Path {
+		path = append(path, node.Point)
+		nodeIDs = append(nodeIDs, int64(node.ID))
+		// the levels in the order they are passed
+		if node.Level != "" && (len(levels) == 0 || levels[len(levels)-1] != node.Level) {
+			levels = append(levels, node.Level)
+		}
+	}
+	if len(path) > 0 {
+		feature := geojson.NewFeature(path)
+		feature.Properties["role"] = "path"
+		feature.Properties["osm_node_ids"] = nodeIDs
+		feature.Properties["distance"] = transfer.PathDistance
+		feature.Properties["weight"] = transfer.PathWeight
+		feature.Properties["levels"] = levels
+		collection.Append(feature)
+	}
+
+	for _, end := range []struct {
+		name string
+		end  models.TransferEnd
+	}{{"source", transfer.Source}, {"dest", transfer.Dest}} {
+		for _, feature := range transferEndFeatures(end.end) {
+			feature.Properties["end"] = end.name
+			feature.Properties["platform"] = end.end.Selection.Platform.FeatureID().String()
+			feature.Properties["service"] = end.end.Selection.Service.FeatureID().String()
+			collection.Append(feature)
+		}
+	}
+
+	if len(transfer.ClosePoints) > 0 {
+		feature := geojson.NewFeature(orb.MultiPoint(transfer.ClosePoints))
+		feature.Properties["role"] = "close_nodes"
+		collection.Append(feature)
+	}
+	return collection
+}
+
+// creates the features of one platform, the properties common to all of them are added by the caller
+func transferEndFeatures(end models.TransferEnd) []*geojson.Feature {
+	var features []*geojson.Feature
+
+	spine := geojson.NewFeature(orb.LineString{end.Spine.Start, end.Spine.End})
+	spine.Properties["role"] = "spine"
+	spine.Properties["orientation"] = spineOrientation
+	spine.Properties["length"] = end.SpineLength
+	spine.Properties["platform_side"] = end.Side.String()
+	spine.Properties["platform_ref"] = end.PlatformRef
+	features = append(features, spine)
+
+	if len(end.StoppingRange) > 0 {
+		stoppingRange := geojson.NewFeature(end.StoppingRange)
+		stoppingRange.Properties["role"] = "stopping_range"
+		stoppingRange.Properties["service_ref"] = end.ServiceRef
+		features = append(features, stoppingRange)
+	}
+
+	for _, ring := range end.TrackBuffers {
+		buffer := geojson.NewFeature(orb.Polygon{ring})
+		buffer.Properties["role"] = "track_buffer"
+		features = append(features, buffer)
+	}
+
+	if end.Exit.ID != 0 {
+		exit := geojson.NewFeature(end.Exit.Point)
+		exit.Properties["role"] = "exit"
+		exit.Properties["osm_node_id"] = int64(end.Exit.ID)
+		exit.Properties["level"] = end.Exit.Level
+		features = append(features, exit)
+	}
+
+	door := geojson.NewFeature(end.Door)
+	door.Properties["role"] = "door"
+	door.Properties["distance"] = end.DoorDistance
+	door.Properties["fraction"] = end.DoorFraction()
+	door.Properties["platform_side"] = end.Side.String()
+	features = append(features, door)
+
+	return features
+}
+
+// WriteGeoJSON writes the feature collection of the transfer
+func WriteGeoJSON(w io.Writer, transfer models.Transfer) error {
+	data, err := json.MarshalIndent(GeoJSON(transfer), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
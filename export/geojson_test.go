@@ -0,0 +1,103 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/jkulzer/osm"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// a transfer from an U1 platform one level down to an U2 platform
+func testTransfer() models.Transfer {
+	return models.Transfer{
+		Source: models.TransferEnd{
+			Selection:     models.PlatformAndServiceSelection{Platform: osm.WayID(100).ElementID(1), Service: 20},
+			PlatformRef:   "1",
+			ServiceName:   "U1: Uhlandstraße => Warschauer Straße",
+			ServiceRef:    "U1",
+			ServiceColour: "#7DAD4C",
+			Spine:         models.PlatformSpine{Start: orb.Point{13.4490, 52.5050}, End: orb.Point{13.4490, 52.5060}},
+			Side:          models.PlatformSideRight,
+			StoppingRange: orb.LineString{{13.4491, 52.5050}, {13.4491, 52.5060}},
+			TrackBuffers:  []orb.Ring{{{13.4490, 52.5050}, {13.4492, 52.5050}, {13.4492, 52.5060}, {13.4490, 52.5060}, {13.4490, 52.5050}}},
+			Exit:          models.PathNode{ID: 1, Point: orb.Point{13.4489, 52.5052}, Level: "0"},
+			Door:          orb.Point{13.4490, 52.5052},
+			DoorDistance:  22.2,
+			SpineLength:   111.2,
+		},
+		Dest: models.TransferEnd{
+			Selection:   models.PlatformAndServiceSelection{Platform: osm.RelationID(200).ElementID(1), Service: 21},
+			ServiceName: "U2: Pankow => Ruhleben",
+			ServiceRef:  "U2",
+			Spine:       models.PlatformSpine{Start: orb.Point{13.4480, 52.5055}, End: orb.Point{13.4470, 52.5055}},
+			Side:        models.PlatformSideLeft,
+			Exit:        models.PathNode{ID: 3, Point: orb.Point{13.4481, 52.5054}, Level: "-1"},
+			Door:        orb.Point{13.4481, 52.5055},
+			SpineLength: 67.8,
+		},
+		Path: []models.PathNode{
+			{ID: 1, Point: orb.Point{13.4489, 52.5052}, Level: "0"},
+			{ID: 2, Point: orb.Point{13.4485, 52.5053}},
+			{ID: 3, Point: orb.Point{13.4481, 52.5054}, Level: "-1"},
+		},
+		PathDistance:  56.4,
+		PathWeight:    50.1,
+		ClosePoints:   []orb.Point{{13.4490, 52.5050}, {13.4490, 52.5060}},
+		WalkingTime:   52 * time.Second,
+		LevelChanges:  1,
+		VerticalMeans: []models.VerticalMeans{{Kind: models.VerticalMeansSteps, Feature: osm.WayID(5).FeatureID()}},
+	}
+}
+
+func TestGeoJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteGeoJSON(&buffer, testTransfer()); err != nil {
+		t.Fatal(err)
+	}
+	collection, err := geojson.UnmarshalFeatureCollection(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := make(map[string]int)
+	for _, feature := range collection.Features {
+		roles[feature.Properties.MustString("role")]++
+	}
+	// the dest platform has neither a stopping range nor track buffers
+	expected := map[string]int{"path": 1, "spine": 2, "stopping_range": 1, "track_buffer": 1, "exit": 2, "door": 2, "close_nodes": 1}
+	for role, count := range expected {
+		if roles[role] != count {
+			t.Errorf("expected %d features with role %s, got %d", count, role, roles[role])
+		}
+	}
+
+	for _, feature := range collection.Features {
+		switch feature.Properties.MustString("role") {
+		case "path":
+			if feature.Properties.MustFloat64("distance") != 56.4 || len(feature.Geometry.(orb.LineString)) != 3 {
+				t.Errorf("unexpected path %v", feature.Properties)
+			}
+		case "exit":
+			if feature.Properties.MustString("end") == "dest" && feature.Properties.MustString("level") != "-1" {
+				t.Errorf("expected the dest exit on level -1, got %v", feature.Properties)
+			}
+		case "spine":
+			if feature.Properties.MustString("end") == "source" && feature.Properties.MustString("platform") != "way/100" {
+				t.Errorf("unexpected source platform %v", feature.Properties["platform"])
+			}
+		}
+	}
+
+	summary, ok := collection.ExtraMembers["summary"].(map[string]any)
+	if !ok {
+		t.Fatalf("the collection has no summary: %v", collection.ExtraMembers)
+	}
+	if summary["walking_time"] != 52.0 || summary["level_changes"] != 1.0 || len(summary["vertical_means"].([]any)) != 1 {
+		t.Errorf("unexpected summary %v", summary)
+	}
+}
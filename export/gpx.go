@@ -0,0 +1,113 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/paulmach/orb"
+)
+
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Namespace string        `xml:"xmlns,attr"`
+	Metadata  gpxMetadata   `xml:"metadata"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+	Track     gpxTrack      `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name      string       `xml:"name"`
+	Desc      string       `xml:"desc"`
+	Copyright gpxCopyright `xml:"copyright"`
+}
+
+type gpxCopyright struct {
+	Author  string `xml:"author,attr"`
+	License string `xml:"license"`
+}
+
+type gpxWaypoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Name string  `xml:"name"`
+	Desc string  `xml:"desc,omitempty"`
+	Type string  `xml:"type"`
+}
+
+type gpxTrack struct {
+	Name    string          `xml:"name"`
+	Desc    string          `xml:"desc"`
+	Segment gpxTrackSegment `xml:"trkseg"`
+}
+
+type gpxTrackSegment struct {
+	Points []gpxTrackPoint `xml:"trkpt"`
+}
+
+type gpxTrackPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+// WriteGPX writes the walking path as a track, with waypoints for the doors and exits.
+// The instructions and the summary are the description of the track.
+func WriteGPX(w io.Writer, transfer models.Transfer) error {
+	name := TransferName(transfer)
+	instructions := strings.Join(append(Instructions(transfer), Summary(transfer)...), "\n")
+	document := gpxDocument{
+		Version:   "1.1",
+		Creator:   "platform-router",
+		Namespace: "http://www.topografix.com/GPX/1/1",
+		Metadata: gpxMetadata{
+			Name: name,
+			Desc: Attribution,
+			Copyright: gpxCopyright{
+				Author:  "OpenStreetMap contributors",
+				License: "https://opendatacommons.org/licenses/odbl/",
+			},
+		},
+		Track: gpxTrack{
+			Name: name,
+			Desc: instructions,
+		},
+	}
+
+	document.Waypoints = append(document.Waypoints, newGPXWaypoint(transfer.Source.Door, "Source door", doorText(transfer.Source), "door"))
+	if transfer.Source.Exit.ID != 0 {
+		document.Waypoints = append(document.Waypoints, newGPXWaypoint(transfer.Source.Exit.Point, "Source exit", exitDesc(transfer.Source.Exit), "exit"))
+	}
+	if transfer.Dest.Exit.ID != 0 {
+		document.Waypoints = append(document.Waypoints, newGPXWaypoint(transfer.Dest.Exit.Point, "Dest exit", exitDesc(transfer.Dest.Exit), "exit"))
+	}
+	document.Waypoints = append(document.Waypoints, newGPXWaypoint(transfer.Dest.Door, "Dest door", doorText(transfer.Dest), "door"))
+
+	for _, node := range transfer.Path {
+		document.Track.Segment.Points = append(document.Track.Segment.Points, gpxTrackPoint{Lat: node.Point.Lat(), Lon: node.Point.Lon()})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(document)
+}
+
+func newGPXWaypoint(point orb.Point, name string, desc string, waypointType string) gpxWaypoint {
+	return gpxWaypoint{Lat: point.Lat(), Lon: point.Lon(), Name: name, Desc: desc, Type: waypointType}
+}
+
+func exitDesc(exit models.PathNode) string {
+	return "OSM node " + fmt.Sprint(exit.ID) + levelText(exit.Level)
+}
+
+// TransferName is the title of a transfer, e.g. "U1 on platform 1 to U2"
+func TransferName(transfer models.Transfer) string {
+	return serviceText(transfer.Source) + " to " + serviceText(transfer.Dest)
+}
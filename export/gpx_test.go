@@ -0,0 +1,32 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestGPX(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteGPX(&buffer, testTransfer()); err != nil {
+		t.Fatal(err)
+	}
+	var document gpxDocument
+	if err := xml.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	// both doors and exits
+	if len(document.Waypoints) != 4 {
+		t.Errorf("expected 4 waypoints, got %d", len(document.Waypoints))
+	}
+	if len(document.Track.Segment.Points) != 3 {
+		t.Errorf("expected 3 track points, got %d", len(document.Track.Segment.Points))
+	}
+	if !strings.Contains(document.Track.Desc, "Walk 56 m") {
+		t.Errorf("instructions are missing from the track description: %s", document.Track.Desc)
+	}
+	if document.Waypoints[0].Lat != 52.5052 || document.Waypoints[0].Lon != 13.4490 {
+		t.Errorf("unexpected position of the source door %f, %f", document.Waypoints[0].Lat, document.Waypoints[0].Lon)
+	}
+}
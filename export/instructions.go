@@ -0,0 +1,87 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jkulzer/platform-router/models"
+)
+
+// Attribution has to be included in every export, the data is from OpenStreetMap
+const Attribution = "Data © OpenStreetMap contributors, available under the Open Database License (ODbL): https://openstreetmap.org/copyright"
+
+// Instructions describes the transfer step by step
+func Instructions(transfer models.Transfer) []string {
+	source := transfer.Source
+	dest := transfer.Dest
+	instructions := []string{
+		"Ride " + serviceText(source) + " in the part of the train " + doorText(source) + ", " + sideText(source.Side) + ".",
+	}
+	if source.Exit.ID != 0 {
+		instructions = append(instructions, "Leave the platform"+levelText(source.Exit.Level)+".")
+	}
+	instructions = append(instructions, fmt.Sprintf("Walk %.0f m to the platform of %s.", transfer.PathDistance, serviceText(dest)))
+	if dest.Exit.ID != 0 {
+		instructions = append(instructions, "Enter the platform"+levelText(dest.Exit.Level)+".")
+	}
+	instructions = append(instructions, "Wait for "+serviceText(dest)+" "+doorText(dest)+", "+sideText(dest.Side)+".")
+	return instructions
+}
+
+// Summary describes the walk and lists everything that was guessed, one line each
+func Summary(transfer models.Transfer) []string {
+	summary := []string{fmt.Sprintf("Walking distance %.0f m, about %s.", transfer.PathDistance, durationText(transfer.WalkingTime))}
+	switch transfer.LevelChanges {
+	case 0:
+		summary = append(summary, "No level changes.")
+	case 1:
+		summary = append(summary, "1 level change.")
+	default:
+		summary = append(summary, fmt.Sprintf("%d level changes.", transfer.LevelChanges))
+	}
+	for _, means := range transfer.VerticalMeans {
+		summary = append(summary, "Uses the "+string(means.Kind)+" "+means.Feature.String()+".")
+	}
+	for _, warning := range transfer.Warnings {
+		summary = append(summary, "Warning: "+warning+".")
+	}
+	return summary
+}
+
+// durationText rounds up to full minutes, only walks shorter than a minute are given in seconds
+func durationText(duration time.Duration) string {
+	if duration < time.Minute {
+		return fmt.Sprintf("%.0f s", duration.Seconds())
+	}
+	return fmt.Sprintf("%.0f min", math.Ceil(duration.Minutes()))
+}
+
+func serviceText(end models.TransferEnd) string {
+	text := end.ServiceRef
+	if text == "" {
+		text = end.ServiceName
+	}
+	if end.PlatformRef != "" {
+		text += " on platform " + end.PlatformRef
+	}
+	return text
+}
+
+func doorText(end models.TransferEnd) string {
+	return fmt.Sprintf("%.0f m from its front (%.0f%% along the platform)", end.DoorDistance, end.DoorFraction()*100)
+}
+
+func sideText(side models.PlatformSide) string {
+	if side == models.PlatformSideUnknown {
+		return "the side of the doors is unknown"
+	}
+	return "the doors open on the " + side.String()
+}
+
+func levelText(level string) string {
+	if level == "" {
+		return ""
+	}
+	return " on level " + level
+}
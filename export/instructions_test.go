@@ -0,0 +1,27 @@
+package export
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSummary(t *testing.T) {
+	transfer := testTransfer()
+	transfer.Warnings = []string{"the side of the dest platform is unknown"}
+	expected := []string{
+		"Walking distance 56 m, about 52 s.",
+		"1 level change.",
+		"Uses the steps way/5.",
+		"Warning: the side of the dest platform is unknown.",
+	}
+	if summary := Summary(transfer); !slices.Equal(summary, expected) {
+		t.Errorf("expected %q, got %q", expected, summary)
+	}
+
+	// minutes are rounded up
+	transfer.WalkingTime = 90 * time.Second
+	if summary := Summary(transfer); summary[0] != "Walking distance 56 m, about 2 min." {
+		t.Errorf("unexpected walking time %q", summary[0])
+	}
+}
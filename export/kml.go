@@ -0,0 +1,144 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jkulzer/platform-router/helpers"
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/paulmach/orb"
+)
+
+// KML colours are aabbggrr
+const kmlSpineColor = "ff808080"
+
+// used for the path if the source service has no valid colour
+var kmlDefaultPathColor = color.RGBA{0, 0, 255, 255}
+
+type kmlDocument struct {
+	XMLName   xml.Name `xml:"kml"`
+	Namespace string   `xml:"xmlns,attr"`
+	Document  kmlBody  `xml:"Document"`
+}
+
+type kmlBody struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description"`
+	Styles      []kmlStyle     `xml:"Style"`
+	Placemarks  []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlStyle struct {
+	ID        string        `xml:"id,attr"`
+	LineStyle *kmlLineStyle `xml:"LineStyle,omitempty"`
+	IconStyle *kmlIconStyle `xml:"IconStyle,omitempty"`
+}
+
+type kmlLineStyle struct {
+	Color string  `xml:"color"`
+	Width float64 `xml:"width"`
+}
+
+type kmlIconStyle struct {
+	Color string  `xml:"color"`
+	Icon  kmlIcon `xml:"Icon"`
+}
+
+type kmlIcon struct {
+	Href string `xml:"href"`
+}
+
+type kmlPlacemark struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description,omitempty"`
+	StyleURL    string         `xml:"styleUrl"`
+	Point       *kmlPoint      `xml:"Point,omitempty"`
+	LineString  *kmlLineString `xml:"LineString,omitempty"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+// WriteKML writes the walking path in the colour of the source service, the platform spines as lines
+// and placemarks for the doors and exits. The description of the document has the instructions and the summary.
+func WriteKML(w io.Writer, transfer models.Transfer) error {
+	description := append(Instructions(transfer), Summary(transfer)...)
+	description = append(description, Attribution)
+	document := kmlDocument{
+		Namespace: "http://www.opengis.net/kml/2.2",
+		Document: kmlBody{
+			Name:        TransferName(transfer),
+			Description: strings.Join(description, "\n"),
+			Styles: []kmlStyle{
+				{ID: "path", LineStyle: &kmlLineStyle{Color: kmlColor(helpers.ServiceColor(transfer.Source.ServiceColour, kmlDefaultPathColor)), Width: 4}},
+				{ID: "spine", LineStyle: &kmlLineStyle{Color: kmlSpineColor, Width: 2}},
+				{ID: "door", IconStyle: &kmlIconStyle{Color: "ff00ff00", Icon: kmlIcon{Href: "http://maps.google.com/mapfiles/kml/shapes/placemark_circle.png"}}},
+				{ID: "exit", IconStyle: &kmlIconStyle{Color: "ff00ffff", Icon: kmlIcon{Href: "http://maps.google.com/mapfiles/kml/shapes/triangle.png"}}},
+			},
+		},
+	}
+
+	var placemarks []kmlPlacemark
+	path := make(orb.LineString, 0, len(transfer.Path))
+	for _, node := range transfer.Path {
+		path = append(path, node.Point)
+	}
+	// a line needs at least two points
+	if len(path) > 1 {
+		placemarks = append(placemarks, newKMLLine("Walking path", fmt.Sprintf("%.0f m", transfer.PathDistance), "path", path))
+	}
+	for _, end := range []struct {
+		name string
+		end  models.TransferEnd
+	}{{"Source", transfer.Source}, {"Dest", transfer.Dest}} {
+		spine := orb.LineString{end.end.Spine.Start, end.end.Spine.End}
+		placemarks = append(placemarks,
+			newKMLLine(end.name+" platform", serviceText(end.end), "spine", spine),
+			newKMLPoint(end.name+" door", doorText(end.end), "door", end.end.Door),
+		)
+		if end.end.Exit.ID != 0 {
+			placemarks = append(placemarks, newKMLPoint(end.name+" exit", exitDesc(end.end.Exit), "exit", end.end.Exit.Point))
+		}
+	}
+	document.Document.Placemarks = placemarks
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(document)
+}
+
+// kmlColor converts the colour to the aabbggrr notation of KML
+func kmlColor(c color.RGBA) string {
+	return fmt.Sprintf("%02x%02x%02x%02x", c.A, c.B, c.G, c.R)
+}
+
+func newKMLLine(name string, description string, style string, line orb.LineString) kmlPlacemark {
+	return kmlPlacemark{Name: name, Description: description, StyleURL: "#" + style, LineString: &kmlLineString{Tessellate: 1, Coordinates: kmlCoordinates(line...)}}
+}
+
+func newKMLPoint(name string, description string, style string, point orb.Point) kmlPlacemark {
+	return kmlPlacemark{Name: name, Description: description, StyleURL: "#" + style, Point: &kmlPoint{Coordinates: kmlCoordinates(point)}}
+}
+
+// KML coordinates are lon,lat tuples separated by spaces
+func kmlCoordinates(points ...orb.Point) string {
+	tuples := make([]string, len(points))
+	for i, point := range points {
+		tuples[i] = strconv.FormatFloat(point.Lon(), 'f', -1, 64) + "," + strconv.FormatFloat(point.Lat(), 'f', -1, 64)
+	}
+	return strings.Join(tuples, " ")
+}
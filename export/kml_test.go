@@ -0,0 +1,50 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestKML(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteKML(&buffer, testTransfer()); err != nil {
+		t.Fatal(err)
+	}
+	var document kmlDocument
+	if err := xml.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	styles := make(map[string]string)
+	for _, style := range document.Document.Styles {
+		if style.LineStyle != nil {
+			styles[style.ID] = style.LineStyle.Color
+		}
+	}
+	// #7DAD4C in aabbggrr
+	if styles["path"] != "ff4cad7d" {
+		t.Errorf("expected the path in the colour of U1, got %s", styles["path"])
+	}
+
+	lines, points := 0, 0
+	for _, placemark := range document.Document.Placemarks {
+		if placemark.LineString != nil {
+			lines++
+		}
+		if placemark.Point != nil {
+			points++
+		}
+	}
+	// path and both spines, both doors and exits
+	if lines != 3 || points != 4 {
+		t.Errorf("expected 3 lines and 4 points, got %d and %d", lines, points)
+	}
+	if document.Document.Placemarks[0].LineString.Coordinates != "13.4489,52.5052 13.4485,52.5053 13.4481,52.5054" {
+		t.Errorf("unexpected path coordinates %s", document.Document.Placemarks[0].LineString.Coordinates)
+	}
+	if !strings.Contains(document.Document.Description, Attribution) {
+		t.Error("the attribution is missing from the document description")
+	}
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jkulzer/platform-router/helpers"
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/paulmach/orb"
+)
+
+const (
+	pathMapWidth  = 640
+	pathMapHeight = 360
+	pathMapMargin = 30
+	// the smallest extent in meters, so a transfer across one platform isn't zoomed in too far
+	pathMapMinExtent = 30
+)
+
+// pathMap draws the platforms, the walking path, the exits and the doors of the transfer seen from above with north up
+func pathMap(transfer models.Transfer) *diagram {
+	d := &diagram{width: pathMapWidth, height: pathMapHeight}
+	d.rect(0, 0, d.width, d.height, schematicBackground)
+
+	points := orb.MultiPoint{transfer.Source.Spine.Start, transfer.Source.Spine.End, transfer.Dest.Spine.Start, transfer.Dest.Spine.End}
+	for _, node := range transfer.Path {
+		points = append(points, node.Point)
+	}
+	project := helpers.LocalProjection(points.Bound().Center())
+	var projected orb.MultiPoint
+	for _, point := range points {
+		projected = append(projected, project(point))
+	}
+	bound := projected.Bound()
+	scale := math.Min(
+		(d.width-2*pathMapMargin)/math.Max(bound.Max.X()-bound.Min.X(), pathMapMinExtent),
+		(d.height-2*pathMapMargin)/math.Max(bound.Max.Y()-bound.Min.Y(), pathMapMinExtent),
+	)
+	toDiagram := func(point orb.Point) orb.Point {
+		meters := project(point)
+		return orb.Point{d.width/2 + meters.X()*scale, d.height/2 - meters.Y()*scale}
+	}
+
+	for _, end := range []struct {
+		name string
+		end  models.TransferEnd
+	}{{"from", transfer.Source}, {"to", transfer.Dest}} {
+		start, stop := toDiagram(end.end.Spine.Start), toDiagram(end.end.Spine.End)
+		d.line(start, stop, 8, schematicPlatform)
+		// the label is at the front of the train
+		d.text(start.X(), start.Y()-8, 11, labelAnchor(start.X(), 0, d.width), end.name+" "+serviceText(end.end)+" (front)")
+	}
+
+	pathColor := helpers.ServiceColor(transfer.Source.ServiceColour, schematicDefaultTrain)
+	for i := 0; i+1 < len(transfer.Path); i++ {
+		d.line(toDiagram(transfer.Path[i].Point), toDiagram(transfer.Path[i+1].Point), 4, pathColor)
+	}
+
+	for _, end := range []models.TransferEnd{transfer.Source, transfer.Dest} {
+		if end.Exit.ID != 0 {
+			exit := toDiagram(end.Exit.Point)
+			d.rect(exit.X()-5, exit.Y()-5, 10, 10, schematicExit)
+		}
+		door := toDiagram(end.Door)
+		d.polygon(schematicText, circle(door, 7)...)
+		d.polygon(helpers.ServiceColor(end.ServiceColour, schematicDefaultTrain), circle(door, 5)...)
+	}
+
+	// the scale bar is about a fifth of the width, rounded to a length that is easy to read
+	barMeters := niceLength((d.width - 2*pathMapMargin) / 5 / scale)
+	barStart := orb.Point{pathMapMargin, d.height - pathMapMargin/2}
+	d.line(barStart, orb.Point{barStart.X() + barMeters*scale, barStart.Y()}, 2, schematicText)
+	d.text(barStart.X()+barMeters*scale+6, barStart.Y()+4, 11, "start", fmt.Sprintf("%g m", barMeters))
+	d.text(d.width-pathMapMargin/2, pathMapMargin/2+4, 12, "end", "N ↑")
+	return d
+}
+
+// circle approximates a circle with a polygon, which is enough at the size of markers
+func circle(center orb.Point, radius float64) []orb.Point {
+	const corners = 16
+	points := make([]orb.Point, corners)
+	for i := range points {
+		angle := 2 * math.Pi * float64(i) / corners
+		points[i] = orb.Point{center.X() + radius*math.Cos(angle), center.Y() + radius*math.Sin(angle)}
+	}
+	return points
+}
+
+// niceLength rounds down to 1, 2 or 5 times a power of ten
+func niceLength(length float64) float64 {
+	if length <= 0 {
+		return 1
+	}
+	power := math.Pow(10, math.Floor(math.Log10(length)))
+	for _, factor := range []float64{5, 2, 1} {
+		if factor*power <= length {
+			return factor * power
+		}
+	}
+	return power
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/paulmach/orb"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// pixels per unit of the schematic in the PNG, so it stays sharp on high resolution screens and in print
+const pngScale = 2
+
+var (
+	regularFont     *opentype.Font
+	regularFontOnce sync.Once
+)
+
+// WriteSchematicPNG writes the same schematic as WriteSchematicSVG as PNG
+func WriteSchematicPNG(w io.Writer, transfer models.Transfer) error {
+	img, err := SchematicImage(transfer, pngScale)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// SchematicImage renders the schematic with scale pixels per unit
+func SchematicImage(transfer models.Transfer, scale float64) (image.Image, error) {
+	return schematic(transfer).render(scale)
+}
+
+func (d *diagram) render(scale float64) (image.Image, error) {
+	regularFontOnce.Do(func() {
+		// the font is embedded, so parsing it can't fail
+		regularFont, _ = opentype.Parse(goregular.TTF)
+	})
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(d.width*scale)), int(math.Ceil(d.height*scale))))
+	// the rasterizer is reset for every shape, so its buffers are only allocated once per image
+	rasterizer := vector.NewRasterizer(img.Bounds().Dx(), img.Bounds().Dy())
+	faces := make(map[float64]font.Face)
+	defer func() {
+		for _, face := range faces {
+			face.Close()
+		}
+	}()
+
+	for _, shape := range d.shapes {
+		switch shape.kind {
+		case shapePolygon:
+			fillPolygon(rasterizer, img, shape.points, scale, shape.color)
+		case shapeLine:
+			// a line is a rectangle around the segment
+			from, to := shape.points[0], shape.points[1]
+			length := math.Hypot(to.X()-from.X(), to.Y()-from.Y())
+			if length == 0 {
+				continue
+			}
+			offsetX := -(to.Y() - from.Y()) / length * shape.width / 2
+			offsetY := (to.X() - from.X()) / length * shape.width / 2
+			fillPolygon(rasterizer, img, []orb.Point{
+				{from.X() + offsetX, from.Y() + offsetY}, {to.X() + offsetX, to.Y() + offsetY},
+				{to.X() - offsetX, to.Y() - offsetY}, {from.X() - offsetX, from.Y() - offsetY},
+			}, scale, shape.color)
+		case shapeText:
+			face, exists := faces[shape.size]
+			if !exists {
+				var err error
+				face, err = opentype.NewFace(regularFont, &opentype.FaceOptions{Size: shape.size * scale, DPI: 72, Hinting: font.HintingFull})
+				if err != nil {
+					return nil, err
+				}
+				faces[shape.size] = face
+			}
+			drawer := font.Drawer{Dst: img, Src: image.NewUniform(shape.color), Face: face}
+			x := shape.points[0].X() * scale
+			switch shape.anchor {
+			case "middle":
+				x -= float64(drawer.MeasureString(shape.text)) / 64 / 2
+			case "end":
+				x -= float64(drawer.MeasureString(shape.text)) / 64
+			}
+			drawer.Dot = fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(shape.points[0].Y() * scale * 64)}
+			drawer.DrawString(shape.text)
+		}
+	}
+	return img, nil
+}
+
+func fillPolygon(rasterizer *vector.Rasterizer, img *image.RGBA, points []orb.Point, scale float64, fill color.RGBA) {
+	bounds := img.Bounds()
+	rasterizer.Reset(bounds.Dx(), bounds.Dy())
+	rasterizer.MoveTo(float32(points[0].X()*scale), float32(points[0].Y()*scale))
+	for _, point := range points[1:] {
+		rasterizer.LineTo(float32(point.X()*scale), float32(point.Y()*scale))
+	}
+	rasterizer.ClosePath()
+	rasterizer.Draw(img, bounds, image.NewUniform(fill), image.Point{})
+}
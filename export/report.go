@@ -0,0 +1,79 @@
+package export
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/jkulzer/platform-router/models"
+)
+
+//go:embed report.html.tmpl
+var reportSource string
+
+var reportTemplate = template.Must(template.New("report").Parse(reportSource))
+
+// ReportEntry is the transfer between two services of a station
+type ReportEntry struct {
+	// From and To name the services, they are needed since a transfer that couldn't be computed doesn't have them
+	From     string
+	To       string
+	Transfer models.Transfer
+	// Err is set if the transfer couldn't be computed
+	Err error
+}
+
+type reportData struct {
+	Station     string
+	Generated   string
+	Attribution string
+	Rows        []reportRow
+}
+
+type reportRow struct {
+	From   string
+	To     string
+	Anchor string
+	Err    string
+	Car    string
+	Door   string
+	Walk   string
+	// the warnings are part of the schematic, the table only counts them
+	Warnings     []string
+	Instructions []string
+	// both are generated by the diagram which escapes all texts
+	Schematic template.HTML
+	PathMap   template.HTML
+}
+
+// WriteReport writes a printable HTML page for a station with a table of the car to board for every transfer
+// and a section per transfer with the instructions, the schematic and a map of the path.
+// The page is self contained, the diagrams are inline SVG.
+func WriteReport(w io.Writer, station string, entries []ReportEntry, generated time.Time) error {
+	data := reportData{
+		Station:     station,
+		Generated:   generated.Format("2006-01-02 15:04"),
+		Attribution: Attribution,
+	}
+	for i, entry := range entries {
+		row := reportRow{From: entry.From, To: entry.To, Anchor: fmt.Sprintf("transfer-%d", i+1)}
+		if entry.Err != nil {
+			row.Err = entry.Err.Error()
+			data.Rows = append(data.Rows, row)
+			continue
+		}
+		transfer := entry.Transfer
+		car, cars := carNumber(transfer.Source)
+		row.Car = fmt.Sprintf("%d of %d", car, cars)
+		row.Door = doorText(transfer.Source)
+		row.Walk = fmt.Sprintf("%.0f m, about %s", transfer.PathDistance, durationText(transfer.WalkingTime))
+		row.Warnings = transfer.Warnings
+		row.Instructions = Instructions(transfer)
+		row.Schematic = template.HTML(schematic(transfer).svg(TransferName(transfer)))
+		row.PathMap = template.HTML(pathMap(transfer).svg(TransferName(transfer)))
+		data.Rows = append(data.Rows, row)
+	}
+	return reportTemplate.Execute(w, data)
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	entries := []ReportEntry{
+		{From: "U1", To: "U2", Transfer: testTransfer()},
+		{From: "U2", To: "U1 <Uhlandstraße>", Err: errors.New("found no spine for the source platform")},
+	}
+	var buffer bytes.Buffer
+	if err := WriteReport(&buffer, "Warschauer Straße", entries, time.Date(2024, 10, 19, 15, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	report := buffer.String()
+	for _, expected := range []string{
+		"<title>Transfers at Warschauer Straße</title>",
+		// the door is 22 m behind the front, in the second of 6 cars
+		`<a href="#transfer-1">2 of 6</a>`,
+		"56 m, about 52 s",
+		`<section id="transfer-1">`,
+		// both diagrams are inline
+		"Ride U1 on platform 1</text>",
+		"N ↑</text>",
+		"found no spine for the source platform",
+		// names are escaped
+		"U1 &lt;Uhlandstraße&gt;",
+		Attribution,
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("report doesn't contain %q", expected)
+		}
+	}
+	if strings.Count(report, "<svg") != 2 {
+		t.Errorf("expected 2 diagrams, got %d", strings.Count(report, "<svg"))
+	}
+	if strings.Contains(report, `<section id="transfer-2">`) {
+		t.Error("the failed transfer has a section")
+	}
+}
@@ -0,0 +1,227 @@
+package export
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/paulmach/orb"
+)
+
+// ResultVersion is the version of the JSON result and of ResultSchema.
+// It is only increased for changes that break consumers, new optional members keep the version.
+// The schema allows unknown members, so results with new members still validate against an older schema.
+const ResultVersion = 1
+
+// ResultSchema is the JSON Schema of the JSON result
+//
+//go:embed result.schema.json
+var ResultSchema []byte
+
+// Result is the JSON result of a transfer.
+// It is separate from the models, so changing them doesn't change the result without changing this too.
+// Positions are longitude and latitude like in GeoJSON, OSM objects are referenced like way/123.
+type Result struct {
+	Version   int             `json:"version"`
+	Selection ResultSelection `json:"selection"`
+	Source    ResultEnd       `json:"source"`
+	Dest      ResultEnd       `json:"dest"`
+	Path      ResultPath      `json:"path"`
+	Metrics   ResultMetrics   `json:"metrics"`
+	Warnings  []string        `json:"warnings"`
+	// DataTimestamp is the time of the newest object in the data, null if the data has no timestamps
+	DataTimestamp *time.Time `json:"data_timestamp"`
+	Attribution   string     `json:"attribution"`
+}
+
+// ResultSelection is the input of the transfer as it was selected
+type ResultSelection struct {
+	Source ResultSelectionEnd `json:"source"`
+	Dest   ResultSelectionEnd `json:"dest"`
+}
+
+type ResultSelectionEnd struct {
+	Platform string `json:"platform"`
+	Service  string `json:"service"`
+}
+
+// ResultEnd is a resolved platform and service of the transfer
+type ResultEnd struct {
+	Platform ResultPlatform `json:"platform"`
+	Service  ResultService  `json:"service"`
+	// Side is left, right or unknown
+	Side  string      `json:"side"`
+	Spine ResultSpine `json:"spine"`
+	// StoppingRange is empty if the track isn't close enough to the spine
+	StoppingRange []ResultPosition `json:"stopping_range"`
+	// Exit is null if no exit was found
+	Exit *ResultNode `json:"exit"`
+	Door ResultDoor  `json:"door"`
+}
+
+type ResultPlatform struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Ref  string `json:"ref"`
+}
+
+type ResultService struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Ref    string `json:"ref"`
+	Colour string `json:"colour"`
+}
+
+// ResultSpine is oriented so that Start is where the front of the train stops
+type ResultSpine struct {
+	Start  ResultPosition `json:"start"`
+	End    ResultPosition `json:"end"`
+	Length float64        `json:"length"`
+}
+
+type ResultDoor struct {
+	Position ResultPosition `json:"position"`
+	// Distance is measured in meters from the front of the train
+	Distance float64 `json:"distance"`
+	// Fraction is how far along the platform the door is, from 0 at the front of the train to 1
+	Fraction float64 `json:"fraction"`
+	// Car is counted from 1 at the front of the train, which is assumed to be as long as the platform
+	Car  int `json:"car"`
+	Cars int `json:"cars"`
+}
+
+type ResultPath struct {
+	Nodes    []ResultNode `json:"nodes"`
+	Distance float64      `json:"distance"`
+	Weight   float64      `json:"weight"`
+}
+
+type ResultNode struct {
+	ID       string         `json:"id"`
+	Position ResultPosition `json:"position"`
+	// Level is left out if the node has no level tag
+	Level string `json:"level,omitempty"`
+}
+
+type ResultMetrics struct {
+	// WalkingDistance is in meters
+	WalkingDistance float64 `json:"walking_distance"`
+	// WalkingTime is in seconds
+	WalkingTime   float64               `json:"walking_time"`
+	LevelChanges  int                   `json:"level_changes"`
+	VerticalMeans []ResultVerticalMeans `json:"vertical_means"`
+}
+
+type ResultVerticalMeans struct {
+	// Kind is steps, escalator or elevator
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
+// ResultPosition is a longitude and latitude
+type ResultPosition [2]float64
+
+// NewResult converts a transfer to its JSON result.
+// Lists are empty instead of null, so consumers don't have to check.
+func NewResult(transfer models.Transfer) Result {
+	result := Result{
+		Version: ResultVersion,
+		Selection: ResultSelection{
+			Source: resultSelectionEnd(transfer.Source.Selection),
+			Dest:   resultSelectionEnd(transfer.Dest.Selection),
+		},
+		Source: resultEnd(transfer.Source),
+		Dest:   resultEnd(transfer.Dest),
+		Path: ResultPath{
+			Nodes:    make([]ResultNode, 0, len(transfer.Path)),
+			Distance: transfer.PathDistance,
+			Weight:   transfer.PathWeight,
+		},
+		Metrics: ResultMetrics{
+			WalkingDistance: transfer.PathDistance,
+			WalkingTime:     transfer.WalkingTime.Seconds(),
+			LevelChanges:    transfer.LevelChanges,
+			VerticalMeans:   make([]ResultVerticalMeans, 0, len(transfer.VerticalMeans)),
+		},
+		Warnings:    append([]string{}, transfer.Warnings...),
+		Attribution: Attribution,
+	}
+	for _, node := range transfer.Path {
+		result.Path.Nodes = append(result.Path.Nodes, resultNode(node))
+	}
+	for _, means := range transfer.VerticalMeans {
+		result.Metrics.VerticalMeans = append(result.Metrics.VerticalMeans, ResultVerticalMeans{Kind: string(means.Kind), ID: means.Feature.String()})
+	}
+	if !transfer.DataTimestamp.IsZero() {
+		timestamp := transfer.DataTimestamp.UTC()
+		result.DataTimestamp = &timestamp
+	}
+	return result
+}
+
+// WriteResult writes the transfer as JSON result, see ResultSchema
+func WriteResult(w io.Writer, transfer models.Transfer) error {
+	data, err := json.MarshalIndent(NewResult(transfer), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func resultSelectionEnd(selection models.PlatformAndServiceSelection) ResultSelectionEnd {
+	return ResultSelectionEnd{
+		Platform: selection.Platform.FeatureID().String(),
+		Service:  selection.Service.FeatureID().String(),
+	}
+}
+
+func resultEnd(end models.TransferEnd) ResultEnd {
+	car, cars := carNumber(end)
+	result := ResultEnd{
+		Platform: ResultPlatform{
+			ID:   end.Selection.Platform.FeatureID().String(),
+			Name: end.PlatformName,
+			Ref:  end.PlatformRef,
+		},
+		Service: ResultService{
+			ID:     end.Selection.Service.FeatureID().String(),
+			Name:   end.ServiceName,
+			Ref:    end.ServiceRef,
+			Colour: end.ServiceColour,
+		},
+		Side: end.Side.String(),
+		Spine: ResultSpine{
+			Start:  resultPosition(end.Spine.Start),
+			End:    resultPosition(end.Spine.End),
+			Length: end.SpineLength,
+		},
+		StoppingRange: make([]ResultPosition, 0, len(end.StoppingRange)),
+		Door: ResultDoor{
+			Position: resultPosition(end.Door),
+			Distance: end.DoorDistance,
+			Fraction: end.DoorFraction(),
+			Car:      car,
+			Cars:     cars,
+		},
+	}
+	for _, point := range end.StoppingRange {
+		result.StoppingRange = append(result.StoppingRange, resultPosition(point))
+	}
+	if end.Exit.ID != 0 {
+		exit := resultNode(end.Exit)
+		result.Exit = &exit
+	}
+	return result
+}
+
+func resultNode(node models.PathNode) ResultNode {
+	return ResultNode{ID: node.ID.FeatureID().String(), Position: resultPosition(node.Point), Level: node.Level}
+}
+
+func resultPosition(point orb.Point) ResultPosition {
+	return ResultPosition{point.Lon(), point.Lat()}
+}
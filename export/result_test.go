@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var updateResult = flag.Bool("update-result", false, "write the JSON result of the test transfer to testdata/result.json")
+
+func resultSchema(t *testing.T) *jsonschema.Schema {
+	t.Helper()
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+	if err := compiler.AddResource("result.schema.json", bytes.NewReader(ResultSchema)); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := compiler.Compile("result.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func resultTransfer() models.Transfer {
+	transfer := testTransfer()
+	transfer.Warnings = []string{"the side of the dest platform is a guess"}
+	transfer.DataTimestamp = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return transfer
+}
+
+func TestResultSchema(t *testing.T) {
+	schema := resultSchema(t)
+	tests := []struct {
+		name     string
+		transfer models.Transfer
+	}{
+		{"complete", resultTransfer()},
+		// nothing was found, lists have to be empty and not null
+		{"empty", models.Transfer{
+			Source: models.TransferEnd{Selection: testTransfer().Source.Selection},
+			Dest:   models.TransferEnd{Selection: testTransfer().Dest.Selection},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := WriteResult(&buffer, test.transfer); err != nil {
+				t.Fatal(err)
+			}
+			var result any
+			if err := json.Unmarshal(buffer.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if err := schema.Validate(result); err != nil {
+				t.Errorf("result doesn't match the schema: %v", err)
+			}
+		})
+	}
+}
+
+// the schema allows unknown members for newer results, so a member added to Result without adding it to the schema is found by comparing the two
+func TestResultSchemaDescribesAllMembers(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(ResultSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(NewResult(resultTransfer()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result map[string]any
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if undescribed := undescribedMembers(schema, "result", result, schema); len(undescribed) != 0 {
+		t.Errorf("members missing from the schema: %v", undescribed)
+	}
+
+	result["unknown"] = true
+	if undescribed := undescribedMembers(schema, "result", result, schema); len(undescribed) != 1 {
+		t.Errorf("expected the unknown member to be found, got %v", undescribed)
+	}
+	if err := resultSchema(t).Validate(result); err != nil {
+		t.Errorf("expected a result with an unknown member to be valid: %v", err)
+	}
+}
+
+// returns the paths of the members of value that aren't properties of their object in the schema
+func undescribedMembers(schema map[string]any, path string, value any, definition map[string]any) []string {
+	resolve := func(definition map[string]any) map[string]any {
+		if ref, ok := definition["$ref"].(string); ok {
+			return schema["$defs"].(map[string]any)[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		}
+		return definition
+	}
+	definition = resolve(definition)
+	var undescribed []string
+	switch value := value.(type) {
+	case map[string]any:
+		// e.g. the exit is either a node or null
+		if options, ok := definition["oneOf"].([]any); ok {
+			for _, option := range options {
+				if resolved := resolve(option.(map[string]any)); resolved["type"] == "object" {
+					definition = resolved
+				}
+			}
+		}
+		properties, _ := definition["properties"].(map[string]any)
+		for key, member := range value {
+			memberDefinition, exists := properties[key]
+			if !exists {
+				undescribed = append(undescribed, path+"."+key)
+				continue
+			}
+			undescribed = append(undescribed, undescribedMembers(schema, path+"."+key, member, memberDefinition.(map[string]any))...)
+		}
+	case []any:
+		items, ok := definition["items"].(map[string]any)
+		if !ok {
+			return nil
+		}
+		for i, item := range value {
+			undescribed = append(undescribed, undescribedMembers(schema, path+"["+strconv.Itoa(i)+"]", item, items)...)
+		}
+	}
+	return undescribed
+}
+
+// the result is compared with the file, so changes to the models don't change the result unnoticed.
+// If a change is intended, update the file with -update-result and increase ResultVersion if it breaks consumers.
+func TestResultGolden(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteResult(&buffer, resultTransfer()); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("testdata", "result.json")
+	if *updateResult {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buffer.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Errorf("the JSON result changed, expected:\n%s\ngot:\n%s", expected, buffer.Bytes())
+	}
+}
@@ -0,0 +1,217 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/jkulzer/platform-router/helpers"
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/paulmach/orb"
+)
+
+// the schematic is drawn in these units, the PNG is rendered at pngScale pixels per unit
+const (
+	schematicWidth       = 640
+	schematicPanelHeight = 150
+	schematicMargin      = 40
+	// of the summary below the platforms
+	schematicLineHeight = 20
+	// used for dividing the train into cars, the actual car length isn't in the data
+	carLength = 20
+)
+
+var (
+	schematicBackground = color.RGBA{255, 255, 255, 255}
+	schematicText       = color.RGBA{0, 0, 0, 255}
+	schematicPlatform   = color.RGBA{170, 170, 170, 255}
+	schematicDoor       = color.RGBA{255, 200, 0, 255}
+	schematicExit       = color.RGBA{0, 110, 60, 255}
+	// used for the train and path if the service has no valid colour
+	schematicDefaultTrain = color.RGBA{0, 120, 255, 255}
+)
+
+type shapeKind int
+
+const (
+	shapePolygon shapeKind = iota
+	shapeLine
+	shapeText
+)
+
+// diagramShape is drawn the same way into the SVG and the PNG, y points down
+type diagramShape struct {
+	kind   shapeKind
+	points []orb.Point
+	color  color.RGBA
+	// width of lines
+	width float64
+	text  string
+	size  float64
+	// anchor of texts at points[0], one of start, middle and end like in SVG
+	anchor string
+}
+
+type diagram struct {
+	width  float64
+	height float64
+	shapes []diagramShape
+}
+
+func (d *diagram) rect(x, y, width, height float64, fill color.RGBA) {
+	d.shapes = append(d.shapes, diagramShape{kind: shapePolygon, color: fill, points: []orb.Point{{x, y}, {x + width, y}, {x + width, y + height}, {x, y + height}}})
+}
+
+func (d *diagram) polygon(fill color.RGBA, points ...orb.Point) {
+	d.shapes = append(d.shapes, diagramShape{kind: shapePolygon, color: fill, points: points})
+}
+
+func (d *diagram) line(from orb.Point, to orb.Point, width float64, stroke color.RGBA) {
+	d.shapes = append(d.shapes, diagramShape{kind: shapeLine, color: stroke, width: width, points: []orb.Point{from, to}})
+}
+
+func (d *diagram) text(x, y float64, size float64, anchor string, text string) {
+	d.shapes = append(d.shapes, diagramShape{kind: shapeText, color: schematicText, points: []orb.Point{{x, y}}, size: size, anchor: anchor, text: text})
+}
+
+// schematic draws both platforms of the transfer below each other and the summary below them.
+// The train travels to the right, so its front is on the right and a platform on its left side is drawn above it.
+func schematic(transfer models.Transfer) *diagram {
+	summary := Summary(transfer)
+	d := &diagram{width: schematicWidth, height: 2*schematicPanelHeight + float64(len(summary))*schematicLineHeight + schematicLineHeight/2}
+	d.rect(0, 0, d.width, d.height, schematicBackground)
+	schematicPanel(d, 0, "Ride", transfer.Source)
+	d.line(orb.Point{0, schematicPanelHeight}, orb.Point{d.width, schematicPanelHeight}, 1, schematicPlatform)
+	schematicPanel(d, schematicPanelHeight, "Change to", transfer.Dest)
+	d.line(orb.Point{0, 2 * schematicPanelHeight}, orb.Point{d.width, 2 * schematicPanelHeight}, 1, schematicPlatform)
+	for i, line := range summary {
+		d.text(schematicMargin, 2*schematicPanelHeight+float64(i+1)*schematicLineHeight, 12, "start", line)
+	}
+	return d
+}
+
+func schematicPanel(d *diagram, top float64, verb string, end models.TransferEnd) {
+	left := float64(schematicMargin)
+	right := d.width - schematicMargin
+	length := right - left
+
+	d.text(left, top+24, 16, "start", verb+" "+serviceText(end))
+	d.text(right, top+24, 12, "end", "direction of travel →")
+
+	// a platform on the left of the train is above it, one on an unknown side is drawn above too
+	platformAbove := end.Side != models.PlatformSideRight
+	trainTop, platformTop := top+52, top+96
+	if platformAbove {
+		platformTop, trainTop = top+52, top+78
+	}
+	const platformHeight, trainHeight = 16, 32
+
+	platformLabel := "Platform"
+	if end.PlatformRef != "" {
+		platformLabel += " " + end.PlatformRef
+	}
+	if end.Side == models.PlatformSideUnknown {
+		platformLabel += " (side unknown)"
+	}
+	// the door is measured from the front of the train, the markers are kept within the platform
+	doorX := min(max(right-length*end.DoorFraction(), left+6), right-6)
+	d.rect(left, platformTop, length, platformHeight, schematicPlatform)
+	// the label is at the end of the platform away from the exit, so they don't overlap
+	if doorX > left+length/2 {
+		d.text(left+6, platformTop+12, 11, "start", platformLabel)
+	} else {
+		d.text(right-6, platformTop+12, 11, "end", platformLabel)
+	}
+
+	trainColor := helpers.ServiceColor(end.ServiceColour, schematicDefaultTrain)
+	// the front of the train is rounded off by a triangle
+	d.polygon(trainColor,
+		orb.Point{left, trainTop}, orb.Point{right - 12, trainTop}, orb.Point{right, trainTop + trainHeight/2},
+		orb.Point{right - 12, trainTop + trainHeight}, orb.Point{left, trainTop + trainHeight},
+	)
+	_, cars := carNumber(end)
+	for car := 1; car < cars; car++ {
+		x := left + length*float64(car)/float64(cars)
+		d.line(orb.Point{x, trainTop}, orb.Point{x, trainTop + trainHeight}, 2, schematicBackground)
+	}
+
+	d.rect(doorX-4, trainTop, 8, trainHeight, schematicDoor)
+	doorLabelY := trainTop + trainHeight + 16
+	exitLabelY := platformTop - 6
+	if !platformAbove {
+		doorLabelY = trainTop - 6
+		exitLabelY = platformTop + platformHeight + 16
+	}
+	d.text(doorX, doorLabelY, 12, labelAnchor(doorX, left, right), fmt.Sprintf("door %.0f m from the front", end.DoorDistance))
+
+	if end.Exit.ID != 0 {
+		// the door is the point on the platform closest to the exit
+		d.rect(doorX-6, platformTop+2, 12, platformHeight-4, schematicExit)
+		d.text(doorX, exitLabelY, 12, labelAnchor(doorX, left, right), "exit"+levelText(end.Exit.Level))
+	}
+}
+
+// carNumber returns the car the door is in, counted from 1 at the front of the train, and the number of cars.
+// The train is assumed to be as long as the platform.
+func carNumber(end models.TransferEnd) (int, int) {
+	cars := max(1, int(math.Round(end.SpineLength/carLength)))
+	car := min(int(end.DoorDistance/carLength)+1, cars)
+	return car, cars
+}
+
+// labels close to the ends of the platform are aligned so they don't leave the diagram
+func labelAnchor(x, left, right float64) string {
+	switch {
+	case x-left < 100:
+		return "start"
+	case right-x < 100:
+		return "end"
+	default:
+		return "middle"
+	}
+}
+
+// WriteSchematicSVG writes a schematic of both platforms with the train and the door to use as SVG
+func WriteSchematicSVG(w io.Writer, transfer models.Transfer) error {
+	_, err := io.WriteString(w, schematic(transfer).svg(TransferName(transfer)))
+	return err
+}
+
+// svg returns the diagram as SVG document with the title and the attribution
+func (d *diagram) svg(title string) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g">`+"\n", d.width, d.height, d.width, d.height)
+	fmt.Fprintf(&builder, "<title>%s</title>\n<desc>%s</desc>\n", escapeXML(title), escapeXML(Attribution))
+	for _, shape := range d.shapes {
+		switch shape.kind {
+		case shapePolygon:
+			points := make([]string, len(shape.points))
+			for i, point := range shape.points {
+				points[i] = fmt.Sprintf("%g,%g", point.X(), point.Y())
+			}
+			fmt.Fprintf(&builder, `<polygon points="%s" fill="%s"/>`+"\n", strings.Join(points, " "), svgColor(shape.color))
+		case shapeLine:
+			fmt.Fprintf(&builder, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s" stroke-width="%g"/>`+"\n",
+				shape.points[0].X(), shape.points[0].Y(), shape.points[1].X(), shape.points[1].Y(), svgColor(shape.color), shape.width)
+		case shapeText:
+			fmt.Fprintf(&builder, `<text x="%g" y="%g" font-family="sans-serif" font-size="%g" text-anchor="%s" fill="%s">%s</text>`+"\n",
+				shape.points[0].X(), shape.points[0].Y(), shape.size, shape.anchor, svgColor(shape.color), escapeXML(shape.text))
+		}
+	}
+	builder.WriteString("</svg>\n")
+	return builder.String()
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func escapeXML(text string) string {
+	var builder strings.Builder
+	xml.EscapeText(&builder, []byte(text))
+	return builder.String()
+}
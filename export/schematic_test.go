@@ -0,0 +1,43 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestSchematicSVG(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteSchematicSVG(&buffer, testTransfer()); err != nil {
+		t.Fatal(err)
+	}
+	var document struct {
+		Texts []string `xml:"text"`
+	}
+	if err := xml.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	texts := strings.Join(document.Texts, "\n")
+	for _, expected := range []string{"Ride U1 on platform 1", "Platform 1", "door 22 m from the front", "exit on level -1"} {
+		if !strings.Contains(texts, expected) {
+			t.Errorf("schematic is missing the label %q", expected)
+		}
+	}
+}
+
+func TestSchematicPNG(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteSchematicPNG(&buffer, testTransfer()); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the panels of both platforms and 3 lines of summary
+	if img.Bounds().Dx() != schematicWidth*pngScale || img.Bounds().Dy() != (2*schematicPanelHeight+3.5*schematicLineHeight)*pngScale {
+		t.Errorf("unexpected size %v", img.Bounds())
+	}
+}
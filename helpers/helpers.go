@@ -1,8 +1,15 @@
 package helpers
 
 import (
+	"cmp"
 	"errors"
+	"image/color"
+	"math"
 	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/paulmach/orb"
 )
 
 func ColorFromString(color string) (int64, int64, int64, error) {
@@ -27,3 +34,58 @@ func ColorFromString(color string) (int64, int64, int64, error) {
 		return 0, 0, 0, errors.New("failed decoding error")
 	}
 }
+
+// ServiceColor converts the colour tag of a service, fallback is used for services without a valid one
+func ServiceColor(colour string, fallback color.RGBA) color.RGBA {
+	red, green, blue, err := ColorFromString(colour)
+	if err != nil {
+		return fallback
+	}
+	return color.RGBA{uint8(red), uint8(green), uint8(blue), 255}
+}
+
+// MetersPerDegree is the length of one degree of latitude
+const MetersPerDegree = 111320
+
+// LocalProjection returns an equirectangular projection to meters east and north of origin, which is exact enough for a station
+func LocalProjection(origin orb.Point) func(point orb.Point) orb.Point {
+	lonFactor := math.Cos(origin.Lat() * math.Pi / 180)
+	return func(point orb.Point) orb.Point {
+		return orb.Point{(point.Lon() - origin.Lon()) * MetersPerDegree * lonFactor, (point.Lat() - origin.Lat()) * MetersPerDegree}
+	}
+}
+
+// CompareNatural compares the strings like strings.Compare, but runs of digits are compared by their value, so U2 comes before U12.
+// Strings that only differ in leading zeros are still ordered, so sorting with it is deterministic.
+func CompareNatural(a, b string) int {
+	x, y := a, b
+	for x != "" && y != "" {
+		xDigits, yDigits := leadingDigits(x), leadingDigits(y)
+		if xDigits > 0 && yDigits > 0 {
+			xNumber, yNumber := strings.TrimLeft(x[:xDigits], "0"), strings.TrimLeft(y[:yDigits], "0")
+			// without leading zeros the longer number is the larger one
+			if result := cmp.Or(cmp.Compare(len(xNumber), len(yNumber)), strings.Compare(xNumber, yNumber)); result != 0 {
+				return result
+			}
+			x, y = x[xDigits:], y[yDigits:]
+			continue
+		}
+		xRune, xSize := utf8.DecodeRuneInString(x)
+		yRune, ySize := utf8.DecodeRuneInString(y)
+		if xRune != yRune {
+			return cmp.Compare(xRune, yRune)
+		}
+		x, y = x[xSize:], y[ySize:]
+	}
+	return cmp.Or(cmp.Compare(len(x), len(y)), strings.Compare(a, b))
+}
+
+// returns how many bytes at the start of the string are digits
+func leadingDigits(text string) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] < '0' || text[i] > '9' {
+			return i
+		}
+	}
+	return len(text)
+}
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"image/color"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestCompareNatural(t *testing.T) {
+	lines := []string{"U12", "S41", "U2", "U1", "RE1", "S8", "U", "U02", "M10", "S42", "U55", "RB10", "RE10"}
+	slices.SortFunc(lines, CompareNatural)
+	expected := []string{"M10", "RB10", "RE1", "RE10", "S8", "S41", "S42", "U", "U1", "U02", "U2", "U12", "U55"}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+	for _, test := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"U2", "U12", -1},
+		{"U12", "U2", 1},
+		{"U2", "U2", 0},
+		{"Bus 100", "Bus 99", 1},
+		{"", "U1", -1},
+	} {
+		if result := CompareNatural(test.a, test.b); result != test.expected {
+			t.Errorf("expected %d comparing %q and %q, got %d", test.expected, test.a, test.b, result)
+		}
+	}
+}
+
+func TestServiceColor(t *testing.T) {
+	fallback := color.RGBA{0, 120, 255, 255}
+	tests := map[string]color.RGBA{
+		"#7DAD4C": {125, 173, 76, 255},
+		"":        fallback,
+		"green":   fallback,
+		"#7DAD4":  fallback,
+	}
+	for colour, expected := range tests {
+		if got := ServiceColor(colour, fallback); got != expected {
+			t.Errorf("expected %v for %q, got %v", expected, colour, got)
+		}
+	}
+}
+
+func TestLocalProjection(t *testing.T) {
+	// a degree of longitude is half as long at 60° north
+	project := LocalProjection(orb.Point{13.4, 60})
+	tests := map[orb.Point]orb.Point{
+		{13.4, 60}:     {0, 0},
+		{13.4, 60.001}: {0, 111.32},
+		{13.402, 59.9}: {111.32, -11132},
+	}
+	for point, expected := range tests {
+		projected := project(point)
+		if math.Abs(projected.X()-expected.X()) > 0.01 || math.Abs(projected.Y()-expected.Y()) > 0.01 {
+			t.Errorf("expected %v for %v, got %v", expected, point, projected)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package ingest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/graph/simple"
+
+	"github.com/rs/zerolog/log"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	"github.com/jkulzer/osm"
+)
+
+// Changes are the final states of all objects touched by an OsmChange file.
+// A nil value means the object was deleted.
+type Changes struct {
+	Nodes     map[osm.NodeID]*osm.Node
+	Ways      map[osm.WayID]*osm.Way
+	Relations map[osm.RelationID]*osm.Relation
+}
+
+// ReadChanges reads an OsmChange (.osc) file, which may be gzip compressed.
+// If an object is changed more than once, the version that comes last in the file is kept.
+func ReadChanges(r io.Reader) (*Changes, error) {
+	bufferedReader := bufio.NewReader(r)
+	magic, err := bufferedReader.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	var input io.Reader = bufferedReader
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(bufferedReader)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		input = gzipReader
+	}
+
+	changes := &Changes{
+		Nodes:     make(map[osm.NodeID]*osm.Node),
+		Ways:      make(map[osm.WayID]*osm.Way),
+		Relations: make(map[osm.RelationID]*osm.Relation),
+	}
+
+	// the file is read as a stream of actions since create, modify and delete blocks can be interleaved
+	decoder := xml.NewDecoder(input)
+	action := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, isStart := token.(xml.StartElement)
+		if !isStart {
+			continue
+		}
+		switch start.Name.Local {
+		case "osmChange":
+		case "create", "modify", "delete":
+			action = start.Name.Local
+		case "node":
+			node := &osm.Node{}
+			if err := decoder.DecodeElement(node, &start); err != nil {
+				return nil, err
+			}
+			if action == "delete" {
+				changes.Nodes[node.ID] = nil
+			} else {
+				changes.Nodes[node.ID] = node
+			}
+		case "way":
+			way := &osm.Way{}
+			if err := decoder.DecodeElement(way, &start); err != nil {
+				return nil, err
+			}
+			if action == "delete" {
+				changes.Ways[way.ID] = nil
+			} else {
+				changes.Ways[way.ID] = way
+			}
+		case "relation":
+			relation := &osm.Relation{}
+			if err := decoder.DecodeElement(relation, &start); err != nil {
+				return nil, err
+			}
+			if action == "delete" {
+				changes.Relations[relation.ID] = nil
+			} else {
+				changes.Relations[relation.ID] = relation
+			}
+		default:
+			if err := decoder.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// ApplyChanges updates the dataset in place with the changes of a replication diff and records its sequence number.
+// Only the graph edges, track bounds and map way entries of ways that changed, or whose nodes changed, are rebuilt.
+// The dataset must not be used by a query while the changes are applied.
+func (d *Dataset) ApplyChanges(changes *Changes, sequence uint64) error {
+	if d.trackBounds == nil || d.MapWays == nil {
+		return errors.New("dataset wasn't loaded with ingest.Load and can't be updated")
+	}
+	if sequence != 0 && sequence <= d.ReplicationSequence {
+		log.Warn().Msg("applying replication sequence " + fmt.Sprint(sequence) + " to a dataset that is already at sequence " + fmt.Sprint(d.ReplicationSequence))
+	}
+	applyStart := time.Now()
+
+	// ways need to be updated if they changed themselves or one of their nodes moved
+	affectedWays := mapset.NewThreadUnsafeSet[osm.WayID]()
+	for id := range changes.Ways {
+		affectedWays.Add(id)
+	}
+	if len(changes.Nodes) > 0 {
+		for _, way := range d.Ways {
+			if !d.MapWays.Contains(way.ID) {
+				continue
+			}
+			for _, wayNode := range way.Nodes {
+				if _, changed := changes.Nodes[wayNode.ID]; changed {
+					affectedWays.Add(way.ID)
+					break
+				}
+			}
+		}
+	}
+
+	// the old edges and bounds are removed before the nodes are updated
+	removedEdges := make(map[nodePair]bool)
+	for id := range affectedWays.Iter() {
+		if oldWay, exists := d.Ways[id]; exists {
+			d.removeWay(oldWay, removedEdges)
+		}
+	}
+
+	var updatedNodes []*osm.Node
+	var deletedNodes []osm.NodeID
+	for id, node := range changes.Nodes {
+		if node == nil {
+			deletedNodes = append(deletedNodes, id)
+		} else {
+			updatedNodes = append(updatedNodes, node)
+			d.Timestamp = later(d.Timestamp, node.Timestamp)
+		}
+	}
+	d.Nodes = d.Nodes.WithChanges(updatedNodes, deletedNodes)
+
+	for id, way := range changes.Ways {
+		if way == nil {
+			delete(d.Ways, id)
+		} else {
+			d.Ways[id] = way
+			d.Timestamp = later(d.Timestamp, way.Timestamp)
+		}
+	}
+	for id := range affectedWays.Iter() {
+		if way, exists := d.Ways[id]; exists {
+			d.addWay(way)
+		}
+	}
+	// an edge can be part of several ways, the ones that didn't change add it back
+	if len(removedEdges) > 0 {
+		for id, way := range d.Ways {
+			if affectedWays.Contains(id) || !IsWalkableWay(way) {
+				continue
+			}
+			for i := 0; i+1 < len(way.Nodes); i++ {
+				if removedEdges[newNodePair(way.Nodes[i].ID, way.Nodes[i+1].ID)] {
+					d.addWayEdges(way)
+					break
+				}
+			}
+		}
+	}
+
+	for id, relation := range changes.Relations {
+		if relation == nil {
+			delete(d.Relations, id)
+		} else {
+			d.Relations[id] = relation
+			d.Timestamp = later(d.Timestamp, relation.Timestamp)
+		}
+	}
+	d.RoutesByMember = buildRouteMemberIndex(context.Background(), d.Relations, nil)
+	// ways that became part of a platform relation are drawn as platforms
+	d.PlatformOutlines = collectPlatformOutlines(d.Relations)
+	for id := range d.PlatformOutlines.Iter() {
+		if way, exists := d.Ways[id]; exists && !d.MapWays.Contains(id) {
+			d.MapWays.Add(way, d.Nodes)
+		}
+	}
+	d.FootWays = collectFootWays(d.Ways)
+
+	if sequence != 0 {
+		d.ReplicationSequence = sequence
+	}
+	log.Info().Msg("applied changes to " + fmt.Sprint(len(changes.Nodes)) + " nodes, " + fmt.Sprint(len(changes.Ways)) + " ways and " + fmt.Sprint(len(changes.Relations)) + " relations in " + fmt.Sprint(time.Since(applyStart)) + ", dataset is at replication sequence " + fmt.Sprint(d.ReplicationSequence))
+	return nil
+}
+
+// the nodes of an edge in either direction, the smaller ID first
+type nodePair [2]osm.NodeID
+
+func newNodePair(a osm.NodeID, b osm.NodeID) nodePair {
+	return nodePair{min(a, b), max(a, b)}
+}
+
+// removes the graph edges and track bounds of a way, the removed edges are recorded so other ways can add them back
+func (d *Dataset) removeWay(way *osm.Way, removedEdges map[nodePair]bool) {
+	if IsWalkableWay(way) {
+		for i := 0; i+1 < len(way.Nodes); i++ {
+			d.Graph.RemoveEdge(int64(way.Nodes[i].ID), int64(way.Nodes[i+1].ID))
+			d.Graph.RemoveEdge(int64(way.Nodes[i+1].ID), int64(way.Nodes[i].ID))
+			removedEdges[newNodePair(way.Nodes[i].ID, way.Nodes[i+1].ID)] = true
+		}
+	}
+	for _, handle := range d.trackBounds[way.ID] {
+		d.TrainTracks.Remove(handle)
+	}
+	delete(d.trackBounds, way.ID)
+	d.MapWays.Remove(way.ID)
+}
+
+// adds the graph edges and track bounds of a way
+func (d *Dataset) addWay(way *osm.Way) {
+	if IsWalkableWay(way) {
+		d.addWayEdges(way)
+	}
+	if IsTrackWay(way) {
+		d.trackBounds[way.ID] = addTrackWay(d.TrainTracks, way, d.Nodes)
+	}
+	if isMapWay(way, d.PlatformOutlines) {
+		d.MapWays.Add(way, d.Nodes)
+	}
+}
+
+// adds the graph edges of a walkable way
+func (d *Dataset) addWayEdges(way *osm.Way) {
+	for _, edge := range wayEdges(way, d.Nodes) {
+		d.Graph.SetWeightedEdge(d.Graph.NewWeightedEdge(simple.Node(edge.from), simple.Node(edge.to), edge.weight))
+	}
+}
+
+// collects all nodes that are part of a footway
+func collectFootWays(ways map[osm.WayID]*osm.Way) mapset.Set[osm.NodeID] {
+	footWays := mapset.NewSet[osm.NodeID]()
+	for _, way := range ways {
+		if way.Tags.Find("highway") == "footway" {
+			for _, node := range way.Nodes {
+				footWays.Add(node.ID)
+			}
+		}
+	}
+	return footWays
+}
+
+// matches the path of a replication diff like .../000/123/456.osc.gz
+var replicationPathPattern = regexp.MustCompile(`(\d{3})[/\\](\d{3})[/\\](\d{3})\.osc(\.gz)?$`)
+
+// matches the sequence number in a replication state file
+var sequenceNumberPattern = regexp.MustCompile(`(?m)^sequenceNumber=(\d+)`)
+
+// SequenceNumberFromPath determines the replication sequence number of a diff file.
+// The state file next to the diff (456.state.txt for 456.osc.gz) is read if it exists,
+// otherwise the number is taken from the directory layout of the replication server (000/123/456.osc.gz is sequence 123456).
+func SequenceNumberFromPath(path string) (uint64, error) {
+	statePath := strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".osc") + ".state.txt"
+	if state, err := os.ReadFile(statePath); err == nil {
+		if match := sequenceNumberPattern.FindSubmatch(state); match != nil {
+			return strconv.ParseUint(string(match[1]), 10, 64)
+		}
+		log.Warn().Msg("state file " + statePath + " has no sequence number")
+	}
+	if match := replicationPathPattern.FindStringSubmatch(filepath.ToSlash(path)); match != nil {
+		return strconv.ParseUint(match[1]+match[2]+match[3], 10, 64)
+	}
+	return 0, errors.New("can't determine the replication sequence number of " + path)
+}
+
+// ApplyChangeFile reads the OsmChange file at the path and applies it to the dataset.
+// The sequence number is determined from the path, if that isn't possible the changes are applied without updating it.
+func (d *Dataset) ApplyChangeFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	changes, err := ReadChanges(file)
+	if err != nil {
+		return err
+	}
+	sequence, err := SequenceNumberFromPath(path)
+	if err != nil {
+		log.Warn().Err(err).Msg("applying changes without replication sequence number")
+	}
+	return d.ApplyChanges(changes, sequence)
+}
@@ -0,0 +1,154 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jkulzer/osm"
+	"github.com/jkulzer/osm/osmtest"
+)
+
+// a footway from 1 over 2 to 3 and a subway track from 4 to 5
+func testObjects() osm.Objects {
+	return osm.Objects{
+		&osm.Node{ID: 1, Lat: 52.5000, Lon: 13.4000},
+		&osm.Node{ID: 2, Lat: 52.5001, Lon: 13.4000},
+		&osm.Node{ID: 3, Lat: 52.5002, Lon: 13.4000},
+		&osm.Node{ID: 4, Lat: 52.5000, Lon: 13.4010},
+		&osm.Node{ID: 5, Lat: 52.5010, Lon: 13.4010},
+		&osm.Way{ID: 10, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}, {ID: 3}}, Tags: osm.Tags{{Key: "highway", Value: "footway"}}, Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		&osm.Way{ID: 11, Nodes: osm.WayNodes{{ID: 4}, {ID: 5}}, Tags: osm.Tags{{Key: "railway", Value: "subway"}}},
+	}
+}
+
+const testChange = `<?xml version="1.0" encoding="UTF-8"?>
+<osmChange version="0.6">
+  <create>
+    <node id="6" version="1" timestamp="2024-05-01T12:00:00Z" lat="52.5003" lon="13.4000"/>
+  </create>
+  <modify>
+    <way id="10" version="2">
+      <nd ref="1"/>
+      <nd ref="2"/>
+      <nd ref="3"/>
+      <nd ref="6"/>
+      <tag k="highway" v="footway"/>
+    </way>
+  </modify>
+  <delete>
+    <way id="11" version="2"/>
+  </delete>
+  <modify>
+    <node id="1" version="2" lat="52.4999" lon="13.4000">
+      <tag k="level" v="-1"/>
+    </node>
+  </modify>
+</osmChange>`
+
+func TestApplyChanges(t *testing.T) {
+	dataset, err := LoadFromScanner(context.Background(), osmtest.NewScanner(testObjects()), Options{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataset.TrainTracks.Len() != 1 {
+		t.Fatalf("expected one track segment, got %d", dataset.TrainTracks.Len())
+	}
+	if !dataset.Timestamp.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the timestamp of the newest object, got %v", dataset.Timestamp)
+	}
+	oldWeight, _ := dataset.Graph.Weight(1, 2)
+
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	gzipWriter.Write([]byte(testChange))
+	gzipWriter.Close()
+	changes, err := ReadChanges(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dataset.ApplyChanges(changes, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	if dataset.ReplicationSequence != 42 {
+		t.Errorf("expected sequence 42, got %d", dataset.ReplicationSequence)
+	}
+	if !dataset.Timestamp.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("timestamp wasn't updated to the newest change, got %v", dataset.Timestamp)
+	}
+	if dataset.Graph.Edge(3, 6) == nil || dataset.Graph.Edge(6, 3) == nil {
+		t.Errorf("edges of the extended footway are missing")
+	}
+	if newWeight, _ := dataset.Graph.Weight(1, 2); newWeight <= oldWeight {
+		t.Errorf("edge weight of the moved node wasn't updated: %v before, %v after", oldWeight, newWeight)
+	}
+	if dataset.TrainTracks.Len() != 0 {
+		t.Errorf("bounds of the deleted track are still indexed")
+	}
+	if _, exists := dataset.Ways[11]; exists {
+		t.Errorf("deleted track way still exists")
+	}
+	if dataset.MapWays.Contains(11) || dataset.MapWays.Len() != 1 {
+		t.Errorf("expected only the footway in the map ways, got %d ways", dataset.MapWays.Len())
+	}
+	if dataset.Nodes.Tags(1).Find("level") != "-1" {
+		t.Errorf("tags of the modified node weren't updated")
+	}
+	if !dataset.FootWays.Contains(osm.NodeID(6)) {
+		t.Errorf("new footway node isn't part of the footways")
+	}
+}
+
+func TestSequenceNumberFromPath(t *testing.T) {
+	sequence, err := SequenceNumberFromPath("replication/minute/006/123/456.osc.gz")
+	if err != nil || sequence != 6123456 {
+		t.Errorf("expected 6123456, got %d (%v)", sequence, err)
+	}
+
+	directory := t.TempDir()
+	diffPath := filepath.Join(directory, "changes.osc")
+	os.WriteFile(filepath.Join(directory, "changes.state.txt"), []byte("#Sat Oct 17 20:21:02 UTC 2026\nsequenceNumber=98765\ntimestamp=2026-10-17T20\\:20\\:52Z\n"), 0o644)
+	sequence, err = SequenceNumberFromPath(diffPath)
+	if err != nil || sequence != 98765 {
+		t.Errorf("expected 98765 from the state file, got %d (%v)", sequence, err)
+	}
+
+	if _, err := SequenceNumberFromPath(strings.TrimSuffix(diffPath, "changes.osc") + "other.osc"); err == nil {
+		t.Errorf("expected an error for a diff without sequence number")
+	}
+}
+
+func TestApplyChangesKeepsSharedEdges(t *testing.T) {
+	// a footway and steps share the segment from 1 to 2
+	objects := osm.Objects{
+		&osm.Node{ID: 1, Lat: 52.5000, Lon: 13.4000},
+		&osm.Node{ID: 2, Lat: 52.5001, Lon: 13.4000},
+		&osm.Node{ID: 3, Lat: 52.5002, Lon: 13.4000},
+		&osm.Way{ID: 10, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}, {ID: 3}}, Tags: osm.Tags{{Key: "highway", Value: "footway"}}},
+		&osm.Way{ID: 11, Nodes: osm.WayNodes{{ID: 2}, {ID: 1}}, Tags: osm.Tags{{Key: "highway", Value: "steps"}}},
+	}
+	dataset, err := LoadFromScanner(context.Background(), osmtest.NewScanner(objects), Options{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := &Changes{
+		Nodes:     map[osm.NodeID]*osm.Node{},
+		Ways:      map[osm.WayID]*osm.Way{10: nil},
+		Relations: map[osm.RelationID]*osm.Relation{},
+	}
+	if err := dataset.ApplyChanges(changes, 0); err != nil {
+		t.Fatal(err)
+	}
+	if dataset.Graph.Edge(1, 2) == nil || dataset.Graph.Edge(2, 1) == nil {
+		t.Errorf("the edge of the steps was removed with the deleted footway")
+	}
+	if dataset.Graph.Edge(2, 3) != nil || dataset.Graph.Edge(3, 2) != nil {
+		t.Errorf("the edge only the deleted footway had is still there")
+	}
+}
@@ -0,0 +1,342 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jkulzer/platform-router/linebound"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/jkulzer/osm"
+	"github.com/jkulzer/osm/osmpbf"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/planar"
+)
+
+// buffer in meters used if none is configured, enough to include the platforms and tracks of a station at the edge of the region
+const DefaultClipBuffer = 200
+
+// Clip is a region the loaded data is restricted to.
+//
+// Clipping is reference complete: a way with at least one node in the region is kept with all of its nodes,
+// and platform relations with a member in the region are kept with all of their member ways.
+// Other relations, e.g. routes, are kept with all members, but only the members that are loaded anyway can be looked up.
+type Clip struct {
+	// Region is an orb.Bound, orb.Polygon or orb.MultiPolygon in WGS84 coordinates
+	Region orb.Geometry
+	// Buffer extends the region by this many meters in every direction
+	Buffer float64
+
+	paddedBound orb.Bound
+}
+
+// NewClip checks the region and precomputes its buffered bounds
+func NewClip(region orb.Geometry, buffer float64) (*Clip, error) {
+	switch region.(type) {
+	case orb.Bound, orb.Polygon, orb.MultiPolygon:
+	default:
+		return nil, errors.New("clip region has to be a bounding box, polygon or multipolygon")
+	}
+	if buffer < 0 {
+		return nil, errors.New("clip buffer can't be negative")
+	}
+	return &Clip{
+		Region:      region,
+		Buffer:      buffer,
+		paddedBound: geo.BoundPad(region.Bound(), buffer),
+	}, nil
+}
+
+// ParseBBox parses a bounding box in the order min lon, min lat, max lon, max lat, e.g. "13.44,52.50,13.46,52.51"
+func ParseBBox(bbox string) (orb.Bound, error) {
+	parts := strings.Split(bbox, ",")
+	if len(parts) != 4 {
+		return orb.Bound{}, errors.New("bounding box " + bbox + " needs 4 comma separated values: min lon, min lat, max lon, max lat")
+	}
+	var values [4]float64
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return orb.Bound{}, errors.New("invalid coordinate " + part + " in bounding box: " + err.Error())
+		}
+		values[i] = value
+	}
+	bound := orb.Bound{Min: orb.Point{values[0], values[1]}, Max: orb.Point{values[2], values[3]}}
+	if bound.Min.Lon() > bound.Max.Lon() || bound.Min.Lat() > bound.Max.Lat() {
+		return orb.Bound{}, errors.New("the minimum of bounding box " + bbox + " is larger than its maximum")
+	}
+	if bound.Min.Lat() < -90 || bound.Max.Lat() > 90 || bound.Min.Lon() < -180 || bound.Max.Lon() > 180 {
+		return orb.Bound{}, errors.New("bounding box " + bbox + " is outside of the valid coordinate range")
+	}
+	return bound, nil
+}
+
+// ReadClipPolygon reads the region from a GeoJSON file.
+// It can be a geometry, a feature or a feature collection, all polygons in it are combined.
+func ReadClipPolygon(r io.Reader) (orb.MultiPolygon, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var object struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	var geometries []orb.Geometry
+	switch object.Type {
+	case "FeatureCollection":
+		collection, err := geojson.UnmarshalFeatureCollection(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, feature := range collection.Features {
+			geometries = append(geometries, feature.Geometry)
+		}
+	case "Feature":
+		feature, err := geojson.UnmarshalFeature(data)
+		if err != nil {
+			return nil, err
+		}
+		geometries = append(geometries, feature.Geometry)
+	default:
+		geometry, err := geojson.UnmarshalGeometry(data)
+		if err != nil {
+			return nil, err
+		}
+		geometries = append(geometries, geometry.Geometry())
+	}
+
+	var region orb.MultiPolygon
+	for _, geometry := range geometries {
+		switch v := geometry.(type) {
+		case orb.Polygon:
+			region = append(region, v)
+		case orb.MultiPolygon:
+			region = append(region, v...)
+		default:
+			log.Debug().Msg("ignoring " + fmt.Sprint(geometry.GeoJSONType()) + " in clip polygon file")
+		}
+	}
+	if len(region) == 0 {
+		return nil, errors.New("the GeoJSON doesn't contain a polygon")
+	}
+	return region, nil
+}
+
+// Contains checks if the point is inside of the region or within the buffer around it
+func (c *Clip) Contains(point orb.Point) bool {
+	if !c.paddedBound.Contains(point) {
+		return false
+	}
+	var polygons orb.MultiPolygon
+	switch region := c.Region.(type) {
+	case orb.Bound:
+		// the padded bound already is the buffered region
+		return true
+	case orb.Polygon:
+		polygons = orb.MultiPolygon{region}
+	case orb.MultiPolygon:
+		polygons = region
+	}
+	if planar.MultiPolygonContains(polygons, point) {
+		return true
+	}
+	if c.Buffer == 0 {
+		return false
+	}
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			for i := 0; i+1 < len(ring); i++ {
+				if linebound.DistanceToSegment(point, ring[i], ring[i+1]) <= c.Buffer {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func isPlatformRelation(relation *osm.Relation) bool {
+	return relation.Tags.Find("railway") == "platform" || relation.Tags.Find("public_transport") == "platform"
+}
+
+// Loads the objects within the clip by reading the file up to three times, so only the IDs of the selected objects have to be kept in memory:
+//  1. nodes in the region, ways with at least one of those nodes and relations with a member that is already selected
+//  2. member ways of platform relations that weren't selected, only if there are any
+//  3. nodes of selected ways outside of the region
+//
+// Relations are selected in the order of the file, so a relation whose only selected member is a relation with a higher ID is missed.
+func loadClipped(ctx context.Context, file io.ReadSeeker, name string, workers int, clip *Clip, report func(Progress)) (*Dataset, error) {
+	clipStart := time.Now()
+	shards := newNodeShards(workers)
+	ways := make(map[osm.WayID]*osm.Way)
+	relations := make(map[osm.RelationID]*osm.Relation)
+	insideNodes := make(map[osm.NodeID]struct{})
+	missingNodes := make(map[osm.NodeID]struct{})
+	missingWays := make(map[osm.WayID]struct{})
+	// only the objects that are kept count for the timestamp of the data
+	var newest time.Time
+
+	keepWay := func(way *osm.Way) {
+		newest = later(newest, way.Timestamp)
+		ways[way.ID] = way
+		for _, node := range way.Nodes {
+			if _, inside := insideNodes[node.ID]; !inside {
+				missingNodes[node.ID] = struct{}{}
+			}
+		}
+	}
+	isSelected := func(member osm.Member) bool {
+		var selected bool
+		switch member.Type {
+		case osm.TypeNode:
+			_, selected = insideNodes[osm.NodeID(member.Ref)]
+		case osm.TypeWay:
+			_, selected = ways[osm.WayID(member.Ref)]
+		case osm.TypeRelation:
+			_, selected = relations[osm.RelationID(member.Ref)]
+		}
+		return selected
+	}
+
+	size := inputSize(file)
+	err := scanPass(ctx, file, name, workers, size, report, PhaseDecode, func(object osm.Object) {
+		switch v := object.(type) {
+		case *osm.Node:
+			if clip.Contains(v.Point()) {
+				newest = later(newest, v.Timestamp)
+				insideNodes[v.ID] = struct{}{}
+				shards.add(v)
+			}
+		case *osm.Way:
+			for _, node := range v.Nodes {
+				if _, inside := insideNodes[node.ID]; inside {
+					keepWay(v)
+					break
+				}
+			}
+		case *osm.Relation:
+			for _, member := range v.Members {
+				if isSelected(member) {
+					newest = later(newest, v.Timestamp)
+					relations[v.ID] = v
+					break
+				}
+			}
+			if _, selected := relations[v.ID]; !selected || !isPlatformRelation(v) {
+				return
+			}
+			// the platform edges and outlines are needed even if they are completely outside of the region
+			for _, member := range v.Members {
+				if _, exists := ways[osm.WayID(member.Ref)]; member.Type == osm.TypeWay && !exists {
+					missingWays[osm.WayID(member.Ref)] = struct{}{}
+				}
+			}
+		}
+	})
+	if err != nil {
+		shards.close()
+		return nil, err
+	}
+	log.Info().Msg("clip selected " + fmt.Sprint(len(insideNodes)) + " nodes, " + fmt.Sprint(len(ways)) + " ways and " + fmt.Sprint(len(relations)) + " relations")
+
+	if len(missingWays) > 0 {
+		log.Debug().Msg("reading " + fmt.Sprint(len(missingWays)) + " member ways of platform relations outside of the clip")
+		err := scanPass(ctx, file, name, workers, size, report, PhaseClip, func(object osm.Object) {
+			if way, ok := object.(*osm.Way); ok {
+				if _, missing := missingWays[way.ID]; missing {
+					keepWay(way)
+				}
+			}
+		}, osm.TypeWay)
+		if err != nil {
+			shards.close()
+			return nil, err
+		}
+	}
+
+	if len(missingNodes) > 0 {
+		log.Debug().Msg("reading " + fmt.Sprint(len(missingNodes)) + " nodes of clipped ways outside of the clip")
+		err := scanPass(ctx, file, name, workers, size, report, PhaseClip, func(object osm.Object) {
+			if node, ok := object.(*osm.Node); ok {
+				if _, missing := missingNodes[node.ID]; missing {
+					newest = later(newest, node.Timestamp)
+					shards.add(node)
+				}
+			}
+		}, osm.TypeNode)
+		if err != nil {
+			shards.close()
+			return nil, err
+		}
+	}
+	shards.close()
+	log.Info().Msg("clipping to " + fmt.Sprint(shards.len()) + " nodes, " + fmt.Sprint(len(ways)) + " ways and " + fmt.Sprint(len(relations)) + " relations took " + fmt.Sprint(time.Since(clipStart)))
+
+	dataset, err := buildDataset(ctx, shards, ways, relations, workers, report)
+	if err != nil {
+		return nil, err
+	}
+	dataset.Timestamp = newest
+	return dataset, nil
+}
+
+// Reads the whole file from the start and hands every object to handle, the bytes read are reported as progress of the phase.
+// If types are given, PBF files skip decoding all other object types.
+func scanPass(ctx context.Context, file io.ReadSeeker, name string, workers int, size int64, report func(Progress), phase Phase, handle func(osm.Object), types ...osm.Type) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	progress, input := newReadProgress(file, size, report, phase)
+	scanner, err := NewScanner(ctx, input, name, workers)
+	if err != nil {
+		return err
+	}
+	defer scanner.Close()
+
+	if closing, ok := scanner.(*closingScanner); ok && len(types) > 0 {
+		if pbfScanner, ok := closing.Scanner.(*osmpbf.Scanner); ok {
+			pbfScanner.SkipNodes, pbfScanner.SkipWays, pbfScanner.SkipRelations = true, true, true
+			for _, objectType := range types {
+				switch objectType {
+				case osm.TypeNode:
+					pbfScanner.SkipNodes = false
+				case osm.TypeWay:
+					pbfScanner.SkipWays = false
+				case osm.TypeRelation:
+					pbfScanner.SkipRelations = false
+				}
+			}
+		}
+	}
+
+	for objectCount := 0; scanner.Scan(); objectCount++ {
+		if objectCount%progressInterval == 0 {
+			if ctx.Err() != nil {
+				break
+			}
+			progress.update()
+		}
+		handle(scanner.Object())
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	progress.finish()
+	return nil
+}
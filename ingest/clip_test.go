@@ -0,0 +1,104 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+)
+
+func TestLoadClipped(t *testing.T) {
+	// square around the stop position, node 6
+	polygon, err := ReadClipPolygon(strings.NewReader(`{"type": "Feature", "properties": {}, "geometry": {"type": "Polygon", "coordinates": [[[13.4491, 52.5054], [13.4493, 52.5054], [13.4493, 52.5056], [13.4491, 52.5056], [13.4491, 52.5054]]]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only contains node 1
+	bbox, err := ParseBBox("13.4489,52.5049,13.4491,52.5051")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		region    orb.Geometry
+		buffer    float64
+		nodes     int
+		ways      int
+		relations int
+	}{
+		// the footway is kept with all of its nodes, the track isn't part of the box
+		{"bbox", bbox, 0, 3, 1, 0},
+		{"bbox with buffer", bbox, 20, 6, 2, 1},
+		{"polygon", polygon, 0, 3, 1, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clip, err := NewClip(test.region, test.buffer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, err := os.Open("testdata/station.osm")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			dataset, err := Load(context.Background(), file, "station.osm", Options{Workers: 2, Clip: clip})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dataset.Nodes.Len() != test.nodes || len(dataset.Ways) != test.ways || len(dataset.Relations) != test.relations {
+				t.Errorf("expected %d nodes, %d ways and %d relations, got %d, %d and %d", test.nodes, test.ways, test.relations, dataset.Nodes.Len(), len(dataset.Ways), len(dataset.Relations))
+			}
+		})
+	}
+}
+
+func TestLoadClippedTimestamp(t *testing.T) {
+	// node 3 and the track are outside of the box and newer than everything that is kept
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="hand edited">
+  <node id="1" version="1" timestamp="2024-01-01T00:00:00Z" lat="52.5050000" lon="13.4490000"/>
+  <node id="2" version="1" timestamp="2024-02-01T00:00:00Z" lat="52.5052000" lon="13.4490000"/>
+  <node id="3" version="1" timestamp="2024-06-01T00:00:00Z" lat="52.5100000" lon="13.4600000"/>
+  <way id="10" version="1" timestamp="2024-03-01T00:00:00Z">
+    <nd ref="1"/>
+    <nd ref="2"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <way id="11" version="1" timestamp="2024-07-01T00:00:00Z">
+    <nd ref="3"/>
+    <nd ref="3"/>
+    <tag k="railway" v="subway"/>
+  </way>
+</osm>`
+	bbox, err := ParseBBox("13.4489,52.5049,13.4491,52.5053")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clip, err := NewClip(bbox, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataset, err := Load(context.Background(), strings.NewReader(data), "clipped.osm", Options{Workers: 2, Clip: clip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dataset.Ways) != 1 {
+		t.Fatalf("expected only the footway to be kept, got %d ways", len(dataset.Ways))
+	}
+	if !dataset.Timestamp.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the timestamp of the newest kept object, got %v", dataset.Timestamp)
+	}
+}
+
+func TestParseBBox(t *testing.T) {
+	for _, bbox := range []string{"13.4,52.5,13.5", "13.5,52.5,13.4,52.6", "13.4,52.5,13.5,abc", "13.4,95,13.5,96"} {
+		if _, err := ParseBBox(bbox); err == nil {
+			t.Errorf("expected an error for bounding box %s", bbox)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/jkulzer/osm"
+	"github.com/jkulzer/osm/osmpbf"
+	"github.com/jkulzer/osm/osmxml"
+)
+
+// Format is the encoding of the OSM objects in an input file
+type Format string
+
+const (
+	FormatUnknown Format = ""
+	FormatPBF     Format = "pbf"
+	FormatXML     Format = "xml"
+	// JSON export of the Overpass API
+	FormatOverpassJSON Format = "overpass-json"
+)
+
+// Compression is the compression wrapped around an input file
+type Compression string
+
+const (
+	CompressionNone  Compression = ""
+	CompressionGzip  Compression = "gzip"
+	CompressionBzip2 Compression = "bzip2"
+)
+
+// enough bytes to see the OSMHeader blob of a PBF file or the start of an XML or JSON document after a byte order mark and whitespace
+const magicLength = 64
+
+func detectCompression(magic []byte, name string) Compression {
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return CompressionGzip
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return CompressionBzip2
+	}
+	// the magic bytes of uncompressed data are checked before falling back to the extension
+	if detectFormat(magic, "") != FormatUnknown {
+		return CompressionNone
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz":
+		return CompressionGzip
+	case ".bz2":
+		return CompressionBzip2
+	}
+	return CompressionNone
+}
+
+func detectFormat(magic []byte, name string) Format {
+	// the first blob of a PBF file is the header blob, its type is stored right after the blob header length
+	if bytes.Contains(magic, []byte("OSMHeader")) {
+		return FormatPBF
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(magic, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) || bytes.HasPrefix(trimmed, []byte("<osm")) {
+		return FormatXML
+	}
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return FormatOverpassJSON
+	}
+
+	// removes the compression extension, e.g. station.osm.bz2 is checked as station.osm
+	lowerName := strings.ToLower(name)
+	extension := filepath.Ext(lowerName)
+	if extension == ".gz" || extension == ".bz2" {
+		extension = filepath.Ext(strings.TrimSuffix(lowerName, extension))
+	}
+	switch extension {
+	case ".pbf":
+		return FormatPBF
+	case ".osm", ".xml":
+		return FormatXML
+	case ".json":
+		return FormatOverpassJSON
+	}
+	return FormatUnknown
+}
+
+// NewScanner detects the format and compression of the data and returns a scanner for its objects.
+// The format is detected from the magic bytes of the data, the file name is only used if they are inconclusive.
+// PBF files are decoded by the given number of goroutines.
+func NewScanner(ctx context.Context, file io.Reader, name string, workers int) (osm.Scanner, error) {
+	bufferedFile := bufio.NewReader(file)
+	magic, err := bufferedFile.Peek(magicLength)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var input io.Reader = bufferedFile
+	var closers []io.Closer
+	compression := detectCompression(magic, name)
+	switch compression {
+	case CompressionGzip:
+		gzipReader, err := gzip.NewReader(bufferedFile)
+		if err != nil {
+			return nil, err
+		}
+		input = gzipReader
+		closers = append(closers, gzipReader)
+	case CompressionBzip2:
+		input = bzip2.NewReader(bufferedFile)
+	}
+	if compression != CompressionNone {
+		bufferedInput := bufio.NewReader(input)
+		magic, err = bufferedInput.Peek(magicLength)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		input = bufferedInput
+	}
+
+	format := detectFormat(magic, name)
+	log.Info().Msg("reading " + name + " as " + string(format) + " with compression " + string(compression))
+	switch format {
+	case FormatPBF:
+		return &closingScanner{Scanner: osmpbf.New(ctx, input, workers), closers: closers}, nil
+	case FormatXML:
+		return &closingScanner{Scanner: osmxml.New(ctx, input), closers: closers}, nil
+	case FormatOverpassJSON:
+		scanner, err := NewOverpassScanner(input)
+		if err != nil {
+			for _, closer := range closers {
+				closer.Close()
+			}
+			return nil, errors.New("failed to read Overpass JSON " + name + ": " + err.Error())
+		}
+		return &closingScanner{Scanner: scanner, closers: closers}, nil
+	default:
+		for _, closer := range closers {
+			closer.Close()
+		}
+		return nil, errors.New("unknown format of OSM file " + name + ", supported are .osm.pbf, .osm and Overpass .json, optionally compressed with gzip or bzip2")
+	}
+}
+
+// closingScanner closes the decompressors of the input together with the scanner
+type closingScanner struct {
+	osm.Scanner
+	closers []io.Closer
+}
+
+func (s *closingScanner) Close() error {
+	err := s.Scanner.Close()
+	for _, closer := range s.closers {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
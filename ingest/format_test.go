@@ -0,0 +1,81 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestLoadFormats(t *testing.T) {
+	tests := []struct {
+		path string
+		name string
+	}{
+		{"testdata/station.osm", "station.osm"},
+		{"testdata/station.osm.gz", "station.osm.gz"},
+		{"testdata/station.osm.bz2", "station.osm.bz2"},
+		// contains elements multiple times like a `out body; >; out skel;` query
+		{"testdata/station.json", "station.json"},
+		// the magic bytes are used if the file name doesn't match the content
+		{"testdata/station.osm.bz2", "download"},
+		{"testdata/station.osm.gz", "station.osm.pbf"},
+	}
+	for _, test := range tests {
+		t.Run(test.path+" as "+test.name, func(t *testing.T) {
+			file, err := os.Open(test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			dataset, err := Load(context.Background(), file, test.name, Options{Workers: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dataset.Nodes.Len() != 6 || len(dataset.Ways) != 2 || len(dataset.Relations) != 1 {
+				t.Errorf("expected 6 nodes, 2 ways and 1 relation, got %d, %d and %d", dataset.Nodes.Len(), len(dataset.Ways), len(dataset.Relations))
+			}
+			// the segment to the elevator is walkable, but weighted more than its length of about 22 m
+			if dataset.Graph.Edge(1, 2) == nil || dataset.Graph.Edge(2, 3) == nil {
+				t.Fatalf("unexpected walking graph")
+			}
+			if weight, _ := dataset.Graph.Weight(2, 3); weight < elevatorPenalty+20 {
+				t.Errorf("expected the segment to the elevator to include the waiting, got a weight of %f", weight)
+			}
+			if dataset.TrainTracks.Len() != 2 {
+				t.Errorf("expected 2 track segments, got %d", dataset.TrainTracks.Len())
+			}
+			if dataset.Nodes.Tags(3).Find("highway") != "elevator" {
+				t.Errorf("tags of node 3 are lost")
+			}
+			if len(dataset.RoutesByMember[dataset.Ways[11].FeatureID()]) != 1 {
+				t.Errorf("route isn't indexed by its track")
+			}
+		})
+	}
+}
+
+func TestLoadUnknownFormat(t *testing.T) {
+	file, err := os.Open("format_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := Load(context.Background(), file, "format_test.go", DefaultOptions()); err == nil {
+		t.Errorf("expected an error for a file that isn't OSM data")
+	}
+}
+
+func TestLoadCancelled(t *testing.T) {
+	file, err := os.Open("testdata/station.osm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dataset, err := Load(ctx, file, "station.osm", Options{Workers: 2})
+	if !errors.Is(err, context.Canceled) || dataset != nil {
+		t.Errorf("expected no dataset and context.Canceled, got %v", err)
+	}
+}
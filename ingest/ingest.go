@@ -0,0 +1,436 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"gonum.org/v1/gonum/graph/simple"
+
+	"github.com/jkulzer/platform-router/linebound"
+	"github.com/jkulzer/platform-router/nodestore"
+
+	"github.com/rs/zerolog/log"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	"github.com/jkulzer/osm"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+)
+
+// how far in meters the bounds around the tracks extend to each side, platform nodes inside of them are considered close to the rails
+const TrackBoundPadding = 3
+
+// railway values of ways trains run on, platform nodes close to them are used for the platform spine
+var validRailwayTags = map[string]bool{
+	"rail":         true,
+	"light_rail":   true,
+	"tram":         true,
+	"subway":       true,
+	"narrow_gauge": true,
+	"monorail":     true,
+}
+
+// the scan loop updates the decode progress every this many objects
+const progressInterval = 1024
+
+// Options configure how OSM data is loaded
+type Options struct {
+	// Workers is the number of goroutines used for decoding, storing nodes and building the graph.
+	// Values below 1 use GOMAXPROCS.
+	Workers int
+	// Clip keeps only the objects within a region, nil loads everything
+	Clip *Clip
+	// Progress is called while loading, possibly from multiple goroutines at once. nil disables progress reporting.
+	Progress func(Progress)
+}
+
+// DefaultOptions returns the options used if nothing is configured
+func DefaultOptions() Options {
+	return Options{
+		Workers: runtime.GOMAXPROCS(0),
+	}
+}
+
+// Dataset is all OSM data needed for routing together with the structures derived from it at load time
+type Dataset struct {
+	Nodes     *nodestore.Store
+	Ways      map[osm.WayID]*osm.Way
+	Relations map[osm.RelationID]*osm.Relation
+	// FootWays contains all nodes that are part of a footway
+	FootWays mapset.Set[osm.NodeID]
+	// Graph is the walking graph, only nodes on walkable ways are part of it
+	Graph          *simple.WeightedDirectedGraph
+	TrainTracks    *linebound.TrackIndex
+	RoutesByMember map[osm.FeatureID][]*osm.Relation
+	// PlatformOutlines contains the member ways of platform relations, which usually have no tags themselves
+	PlatformOutlines mapset.Set[osm.WayID]
+	// MapWays is the spatial index of the platforms, platform outlines, tracks and walkable ways, for finding them around a station
+	MapWays *linebound.WayIndex
+	// ReplicationSequence is the sequence number of the last replication diff applied to the dataset, 0 if none was applied
+	ReplicationSequence uint64
+	// Timestamp is the time of the newest object in the data, zero if the data has no timestamps like Overpass skel output
+	Timestamp time.Time
+
+	// handles of the bounds in TrainTracks for every track way, so they can be removed when the way changes
+	trackBounds map[osm.WayID][]int
+}
+
+// nodeLookup gives access to node locations and tags while building graph edges.
+// It is implemented by the node shards during loading and the node store afterwards.
+type nodeLookup interface {
+	Point(id osm.NodeID) (orb.Point, bool)
+	Tags(id osm.NodeID) osm.Tags
+}
+
+// Load reads an OSM file and builds the walking graph and the indexes used for routing.
+// PBF, XML and Overpass JSON files are supported, optionally compressed with gzip or bzip2 (see NewScanner). name is the file name used for format detection.
+// With a clip configured the file is read multiple times, so it has to implement io.Seeker.
+//
+// PBF files are decoded by the configured number of goroutines and nodes are distributed to sharded stores while decoding.
+// Once all nodes are known, graph edges, the track index and the route index are built concurrently,
+// so the order of the objects in the file doesn't matter.
+//
+// Once ctx is cancelled, all goroutines stop and ctx.Err() is returned without keeping any of the loaded data.
+func Load(ctx context.Context, file io.Reader, name string, options Options) (*Dataset, error) {
+	workers := options.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	log.Info().Msg("loading OSM data with " + fmt.Sprint(workers) + " workers")
+
+	if options.Clip != nil {
+		seeker, ok := file.(io.ReadSeeker)
+		if !ok {
+			return nil, errors.New("clipping needs to read " + name + " multiple times, which isn't possible for this input")
+		}
+		return loadClipped(ctx, seeker, name, workers, options.Clip, options.Progress)
+	}
+
+	progress, input := newReadProgress(file, inputSize(file), options.Progress, PhaseDecode)
+	scanner, err := NewScanner(ctx, input, name, workers)
+	if err != nil {
+		return nil, err
+	}
+	defer scanner.Close()
+
+	return loadFromScanner(ctx, scanner, Options{Workers: workers, Progress: options.Progress}, progress)
+}
+
+// LoadFromScanner builds the dataset from the objects of any scanner, see Load for details.
+// Decoding progress isn't reported since the size of the input is unknown.
+func LoadFromScanner(ctx context.Context, scanner osm.Scanner, options Options) (*Dataset, error) {
+	return loadFromScanner(ctx, scanner, options, nil)
+}
+
+func loadFromScanner(ctx context.Context, scanner osm.Scanner, options Options, progress *readProgress) (*Dataset, error) {
+	workers := options.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	decodeStart := time.Now()
+	shards := newNodeShards(workers)
+	ways := make(map[osm.WayID]*osm.Way)
+	relations := make(map[osm.RelationID]*osm.Relation)
+	var newest time.Time
+
+	for objectCount := 0; scanner.Scan(); objectCount++ {
+		if objectCount%progressInterval == 0 {
+			if ctx.Err() != nil {
+				break
+			}
+			progress.update()
+		}
+		switch v := scanner.Object().(type) {
+		case *osm.Node:
+			newest = later(newest, v.Timestamp)
+			shards.add(v)
+		case *osm.Way:
+			newest = later(newest, v.Timestamp)
+			ways[v.ID] = v
+		case *osm.Relation:
+			newest = later(newest, v.Timestamp)
+			relations[v.ID] = v
+		default:
+			// other OSM object types aren't needed
+		}
+	}
+	shards.close()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	progress.finish()
+	log.Info().Msg("decoding " + fmt.Sprint(shards.len()) + " nodes, " + fmt.Sprint(len(ways)) + " ways and " + fmt.Sprint(len(relations)) + " relations took " + fmt.Sprint(time.Since(decodeStart)))
+
+	dataset, err := buildDataset(ctx, shards, ways, relations, workers, options.Progress)
+	if err != nil {
+		return nil, err
+	}
+	dataset.Timestamp = newest
+	return dataset, nil
+}
+
+func later(a time.Time, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// builds the graph and indexes from the decoded objects, the shards have to be closed already
+func buildDataset(ctx context.Context, shards *nodeShards, ways map[osm.WayID]*osm.Way, relations map[osm.RelationID]*osm.Relation, workers int, report func(Progress)) (*Dataset, error) {
+	dataset := &Dataset{
+		Ways:             ways,
+		Relations:        relations,
+		FootWays:         mapset.NewSet[osm.NodeID](),
+		PlatformOutlines: collectPlatformOutlines(relations),
+	}
+
+	buildStart := time.Now()
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		dataset.Nodes = shards.merge()
+		dataset.TrainTracks, dataset.trackBounds = buildTrackIndex(ctx, dataset.Nodes, ways, newPhaseProgress(report, PhaseTrackIndex, int64(len(ways))))
+		log.Info().Msg("indexed " + fmt.Sprint(dataset.TrainTracks.Len()) + " track segments")
+		dataset.MapWays = buildMapWayIndex(ctx, dataset.Nodes, ways, dataset.PlatformOutlines)
+		log.Info().Msg("indexed " + fmt.Sprint(dataset.MapWays.Len()) + " ways for the station map")
+	}()
+	go func() {
+		defer wg.Done()
+		dataset.Graph = buildGraph(ctx, ways, shards, workers, dataset.FootWays, report)
+	}()
+	go func() {
+		defer wg.Done()
+		dataset.RoutesByMember = buildRouteMemberIndex(ctx, relations, newPhaseProgress(report, PhaseMatching, int64(len(relations))))
+	}()
+	wg.Wait()
+	// the partially built structures are dropped together with the dataset
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	log.Info().Msg("building graph and indexes took " + fmt.Sprint(time.Since(buildStart)))
+
+	return dataset, nil
+}
+
+// Creates the spatial index of the bounds around all tracks for the platform proximity calculation
+func buildTrackIndex(ctx context.Context, nodes *nodestore.Store, ways map[osm.WayID]*osm.Way, progress *phaseProgress) (*linebound.TrackIndex, map[osm.WayID][]int) {
+	trainTracks := linebound.NewTrackIndex(nil)
+	trackBounds := make(map[osm.WayID][]int)
+	var done int64
+	for _, way := range ways {
+		if done%progressInterval == 0 && ctx.Err() != nil {
+			return trainTracks, trackBounds
+		}
+		if IsTrackWay(way) {
+			trackBounds[way.ID] = addTrackWay(trainTracks, way, nodes)
+		}
+		done++
+		progress.update(done)
+	}
+	progress.finish()
+	return trainTracks, trackBounds
+}
+
+// Creates the spatial index of the ways that are drawn on the station map or walked along, see Dataset.MapWays
+func buildMapWayIndex(ctx context.Context, nodes *nodestore.Store, ways map[osm.WayID]*osm.Way, platformOutlines mapset.Set[osm.WayID]) *linebound.WayIndex {
+	mapWays := linebound.NewWayIndex()
+	done := 0
+	for _, way := range ways {
+		if done%progressInterval == 0 && ctx.Err() != nil {
+			return mapWays
+		}
+		done++
+		if isMapWay(way, platformOutlines) {
+			mapWays.Add(way, nodes)
+		}
+	}
+	return mapWays
+}
+
+func isMapWay(way *osm.Way, platformOutlines mapset.Set[osm.WayID]) bool {
+	return IsPlatform(way.Tags) || IsTrackWay(way) || IsWalkableWay(way) || platformOutlines.Contains(way.ID)
+}
+
+// collects the member ways of all platform relations
+func collectPlatformOutlines(relations map[osm.RelationID]*osm.Relation) mapset.Set[osm.WayID] {
+	platformOutlines := mapset.NewSet[osm.WayID]()
+	for _, relation := range relations {
+		if !isPlatformRelation(relation) {
+			continue
+		}
+		for _, member := range relation.Members {
+			if member.Type == osm.TypeWay {
+				platformOutlines.Add(osm.WayID(member.Ref))
+			}
+		}
+	}
+	return platformOutlines
+}
+
+// IsTrackWay reports if trains run on the way
+func IsTrackWay(way *osm.Way) bool {
+	return validRailwayTags[way.Tags.Find("railway")]
+}
+
+// adds the bounds around every segment of the track way to the index and returns their handles
+func addTrackWay(trainTracks *linebound.TrackIndex, way *osm.Way, nodes *nodestore.Store) []int {
+	var handles []int
+	for _, bound := range linebound.TrackBounds([]*osm.Way{way}, nodes, TrackBoundPadding) {
+		handles = append(handles, trainTracks.Add(bound))
+	}
+	return handles
+}
+
+// Creates a reverse index from every member of a route to the routes it is part of.
+// This makes looking up the services of a platform a map access instead of a search through all routes.
+func buildRouteMemberIndex(ctx context.Context, relations map[osm.RelationID]*osm.Relation, progress *phaseProgress) map[osm.FeatureID][]*osm.Relation {
+	routesByMember := make(map[osm.FeatureID][]*osm.Relation)
+	var done int64
+	defer progress.finish()
+	for _, relation := range relations {
+		if done%progressInterval == 0 && ctx.Err() != nil {
+			return routesByMember
+		}
+		done++
+		progress.update(done)
+		if relation.Tags.Find("type") != "route" {
+			continue
+		}
+		for _, member := range relation.Members {
+			memberID := member.FeatureID()
+			// a route can list the same platform more than once, e.g. on loops
+			if len(routesByMember[memberID]) > 0 && routesByMember[memberID][len(routesByMember[memberID])-1] == relation {
+				continue
+			}
+			routesByMember[memberID] = append(routesByMember[memberID], relation)
+		}
+	}
+	return routesByMember
+}
+
+type graphEdge struct {
+	from   osm.NodeID
+	to     osm.NodeID
+	weight float64
+}
+
+// Builds the walking graph from all footways and steps.
+// The edges of a way are computed by the workers, only inserting them into the graph happens on one goroutine since the graph isn't safe for concurrent use.
+func buildGraph(ctx context.Context, ways map[osm.WayID]*osm.Way, shards *nodeShards, workers int, footWays mapset.Set[osm.NodeID], report func(Progress)) *simple.WeightedDirectedGraph {
+	var walkableWays []*osm.Way
+	for _, way := range ways {
+		if IsWalkableWay(way) {
+			walkableWays = append(walkableWays, way)
+		}
+	}
+
+	edgeChan := make(chan []graphEdge, workers)
+	var wg sync.WaitGroup
+	chunkSize := (len(walkableWays) + workers - 1) / workers
+	for start := 0; start < len(walkableWays); start += chunkSize {
+		chunk := walkableWays[start:min(start+chunkSize, len(walkableWays))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, way := range chunk {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				edgeChan <- wayEdges(way, shards)
+				// checks if the way is a footpath
+				if way.Tags.Find("highway") == "footway" {
+					for _, node := range way.Nodes {
+						footWays.Add(node.ID)
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(edgeChan)
+	}()
+
+	// every way sends its edges separately, so the inserted ways can be counted
+	progress := newPhaseProgress(report, PhaseGraph, int64(len(walkableWays)))
+	var done int64
+	g := simple.NewWeightedDirectedGraph(1, 0)
+	for edges := range edgeChan {
+		for _, edge := range edges {
+			g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(edge.from), simple.Node(edge.to), edge.weight))
+		}
+		done++
+		progress.update(done)
+	}
+	progress.finish()
+	return g
+}
+
+// IsWalkableWay reports if the way is part of the walking graph
+func IsWalkableWay(way *osm.Way) bool {
+	return way.Tags.Find("highway") == "footway" || way.Tags.Find("highway") == "steps"
+}
+
+// WalkingSpeed is the usual walking speed in meters per second, the weights of the walking graph are in meters
+const WalkingSpeed = 1.3
+
+// ElevatorSeconds is the time for waiting for and riding an elevator
+const ElevatorSeconds = 45
+
+// the weight added to every segment to or from an elevator. Passing through one uses two segments,
+// so it costs as much as walking for ElevatorSeconds and the stairs are taken if they aren't much longer.
+const elevatorPenalty = ElevatorSeconds * WalkingSpeed / 2
+
+// Computes the graph edges for every segment of a walkable way
+func wayEdges(way *osm.Way, nodes nodeLookup) []graphEdge {
+	var edges []graphEdge
+	conveying := way.Tags.Find("conveying")
+	// the number of edges in a series of edges is node count - 1
+	for i := 0; i+1 < len(way.Nodes); i++ {
+		thisID := way.Nodes[i].ID
+		nextID := way.Nodes[i+1].ID
+		thisPoint, thisExists := nodes.Point(thisID)
+		nextPoint, nextExists := nodes.Point(nextID)
+		if !thisExists || !nextExists {
+			log.Debug().Msg("skipping segment of way " + fmt.Sprint(way.ID) + " since one of its nodes is missing")
+			continue
+		}
+		if thisID == nextID {
+			continue
+		}
+		nodeDistance := geo.Distance(thisPoint, nextPoint)
+		// waiting for the elevator makes it longer than its distance
+		var penalty float64
+		if nodes.Tags(thisID).Find("highway") == "elevator" || nodes.Tags(nextID).Find("highway") == "elevator" {
+			penalty = elevatorPenalty
+		}
+
+		// TODO: Add very high penalty for walking in the wrong direction of an escalator
+
+		// only allow walking along escalators the right direction
+		switch conveying {
+		case "forward":
+			edges = append(edges, graphEdge{from: thisID, to: nextID, weight: nodeDistance*0.5 + penalty})
+		case "backward":
+			edges = append(edges, graphEdge{from: nextID, to: thisID, weight: nodeDistance*0.5 + penalty})
+		default:
+			// if it is only a basic walkway
+			edges = append(edges, graphEdge{from: thisID, to: nextID, weight: nodeDistance + penalty})
+			edges = append(edges, graphEdge{from: nextID, to: thisID, weight: nodeDistance + penalty})
+		}
+	}
+	return edges
+}
@@ -0,0 +1,89 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/jkulzer/osm"
+)
+
+// NewOverpassScanner reads an Overpass API JSON export, e.g. the result of `[out:json]; ...; out body; >; out skel;`.
+// The file is decoded completely before scanning, which is fine for the size of exports of single stations.
+//
+// Overpass outputs an element again for every statement that matches it, with `out skel` without tags.
+// Of all copies of an element only the most complete one is kept.
+func NewOverpassScanner(r io.Reader) (osm.Scanner, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var export osm.OSM
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+
+	scanner := &overpassScanner{}
+	// keyed by type and ref, the object ID also contains the version which `out skel` leaves out
+	type elementKey struct {
+		elementType osm.Type
+		ref         int64
+	}
+	indexes := make(map[elementKey]int)
+	add := func(object osm.Object, completeness int) {
+		key := elementKey{object.ObjectID().Type(), object.ObjectID().Ref()}
+		if index, exists := indexes[key]; exists {
+			if completeness > scanner.completeness[index] {
+				scanner.objects[index] = object
+				scanner.completeness[index] = completeness
+			}
+			return
+		}
+		indexes[key] = len(scanner.objects)
+		scanner.objects = append(scanner.objects, object)
+		scanner.completeness = append(scanner.completeness, completeness)
+	}
+	for _, node := range export.Nodes {
+		add(node, len(node.Tags))
+	}
+	for _, way := range export.Ways {
+		add(way, len(way.Tags)+len(way.Nodes))
+	}
+	for _, relation := range export.Relations {
+		add(relation, len(relation.Tags)+len(relation.Members))
+	}
+	log.Debug().Msg("read " + fmt.Sprint(len(scanner.objects)) + " elements from Overpass export")
+	return scanner, nil
+}
+
+// overpassScanner scans the objects of a decoded Overpass export
+type overpassScanner struct {
+	objects      osm.Objects
+	completeness []int
+	index        int
+	current      osm.Object
+}
+
+func (s *overpassScanner) Scan() bool {
+	if s.index >= len(s.objects) {
+		s.current = nil
+		return false
+	}
+	s.current = s.objects[s.index]
+	s.index++
+	return true
+}
+
+func (s *overpassScanner) Object() osm.Object {
+	return s.current
+}
+
+func (s *overpassScanner) Err() error {
+	return nil
+}
+
+func (s *overpassScanner) Close() error {
+	return nil
+}
@@ -0,0 +1,144 @@
+package ingest
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Phase is a step of loading OSM data
+type Phase string
+
+const (
+	// PhaseDecode is reading the input file, counted in bytes
+	PhaseDecode Phase = "decode"
+	// PhaseClip is reading the input file again for the objects referenced from within a clip, counted in bytes
+	PhaseClip Phase = "clip"
+	// PhaseGraph is building the walking graph, counted in walkable ways
+	PhaseGraph Phase = "graph"
+	// PhaseTrackIndex is building the spatial index of the tracks, counted in ways
+	PhaseTrackIndex Phase = "track index"
+	// PhaseMatching is building the index from platforms to the services stopping at them, counted in relations
+	PhaseMatching Phase = "matching"
+)
+
+// Progress is reported while loading. The phases after decoding run concurrently.
+type Progress struct {
+	Phase Phase
+	Done  int64
+	// Total is 0 if it isn't known, e.g. for decoding data that isn't read from a file
+	Total int64
+}
+
+// Fraction returns how much of the phase is done between 0 and 1, or -1 if the total isn't known
+func (p Progress) Fraction() float64 {
+	if p.Total <= 0 {
+		return -1
+	}
+	return min(float64(p.Done)/float64(p.Total), 1)
+}
+
+// LogProgress writes progress to the log, it can be used as Options.Progress directly or called from another callback
+func LogProgress(progress Progress) {
+	if progress.Total <= 0 {
+		log.Debug().Msg(string(progress.Phase) + ": " + fmt.Sprint(progress.Done))
+		return
+	}
+	log.Debug().Msg(string(progress.Phase) + ": " + fmt.Sprintf("%.0f", progress.Fraction()*100) + "%")
+}
+
+// phaseProgress reports the progress of one phase whenever another percent is done, so the callback isn't flooded.
+// It must only be used from one goroutine, a nil phaseProgress reports nothing.
+type phaseProgress struct {
+	report      func(Progress)
+	phase       Phase
+	total       int64
+	lastPercent int64
+}
+
+func newPhaseProgress(report func(Progress), phase Phase, total int64) *phaseProgress {
+	p := &phaseProgress{report: report, phase: phase, total: total, lastPercent: -1}
+	p.update(0)
+	return p
+}
+
+func (p *phaseProgress) update(done int64) {
+	if p == nil || p.report == nil {
+		return
+	}
+	// only the decode phase can have an unknown total, then every megabyte is reported
+	percent := done / (1 << 20)
+	if p.total > 0 {
+		percent = done * 100 / p.total
+	}
+	if percent == p.lastPercent {
+		return
+	}
+	p.lastPercent = percent
+	p.report(Progress{Phase: p.phase, Done: done, Total: p.total})
+}
+
+// finish reports the phase as completely done
+func (p *phaseProgress) finish() {
+	if p == nil || p.report == nil {
+		return
+	}
+	if p.total > 0 {
+		p.report(Progress{Phase: p.phase, Done: p.total, Total: p.total})
+	} else {
+		p.report(Progress{Phase: p.phase, Done: 1, Total: 1})
+	}
+}
+
+// countingReader counts the bytes read from the input, the decoder reads on another goroutine than the one reporting progress
+type countingReader struct {
+	reader io.Reader
+	read   atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read.Add(int64(n))
+	return n, err
+}
+
+// readProgress reports how much of the input file has been read
+type readProgress struct {
+	reader *countingReader
+	phase  *phaseProgress
+}
+
+// wraps the file, the returned reader has to be used instead of it
+func newReadProgress(file io.Reader, size int64, report func(Progress), phase Phase) (*readProgress, io.Reader) {
+	reader := &countingReader{reader: file}
+	return &readProgress{reader: reader, phase: newPhaseProgress(report, phase, size)}, reader
+}
+
+// update can be called on a nil readProgress if the input isn't known, e.g. for LoadFromScanner
+func (p *readProgress) update() {
+	if p != nil {
+		p.phase.update(p.reader.read.Load())
+	}
+}
+
+func (p *readProgress) finish() {
+	if p != nil {
+		p.phase.finish()
+	}
+}
+
+// returns the size of the input if it is a regular file, 0 otherwise
+func inputSize(file io.Reader) int64 {
+	stater, ok := file.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		return 0
+	}
+	info, err := stater.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	return info.Size()
+}
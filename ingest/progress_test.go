@@ -0,0 +1,44 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestLoadProgress(t *testing.T) {
+	file, err := os.Open("testdata/station.osm.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mutex sync.Mutex
+	last := make(map[Phase]Progress)
+	report := func(progress Progress) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if previous, exists := last[progress.Phase]; exists && previous.Done > progress.Done {
+			t.Errorf("progress of %s went back from %d to %d", progress.Phase, previous.Done, progress.Done)
+		}
+		last[progress.Phase] = progress
+	}
+	if _, err := Load(context.Background(), file, "station.osm.gz", Options{Workers: 2, Progress: report}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the compressed bytes are counted, not the decompressed ones
+	if last[PhaseDecode].Total != info.Size() {
+		t.Errorf("expected the file size %d as total, got %d", info.Size(), last[PhaseDecode].Total)
+	}
+	for _, phase := range []Phase{PhaseDecode, PhaseGraph, PhaseTrackIndex, PhaseMatching} {
+		if last[phase].Fraction() != 1 {
+			t.Errorf("phase %s ended at %f", phase, last[phase].Fraction())
+		}
+	}
+}
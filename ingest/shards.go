@@ -0,0 +1,94 @@
+package ingest
+
+import (
+	"sync"
+
+	"github.com/jkulzer/platform-router/nodestore"
+
+	"github.com/jkulzer/osm"
+	"github.com/paulmach/orb"
+)
+
+// nodes are handed to the shards in batches to keep the channel overhead low
+const nodeBatchSize = 4096
+
+// nodeShards builds several node stores that are each filled by their own goroutine,
+// so inserting nodes while decoding doesn't need any locking.
+// Once closed, the shards are only read and can be used from multiple goroutines.
+type nodeShards struct {
+	stores  []*nodestore.Store
+	inputs  []chan []*osm.Node
+	batches [][]*osm.Node
+	wg      sync.WaitGroup
+}
+
+func newNodeShards(count int) *nodeShards {
+	shards := &nodeShards{
+		stores:  make([]*nodestore.Store, count),
+		inputs:  make([]chan []*osm.Node, count),
+		batches: make([][]*osm.Node, count),
+	}
+	for i := range count {
+		shards.inputs[i] = make(chan []*osm.Node, 4)
+		shards.batches[i] = make([]*osm.Node, 0, nodeBatchSize)
+		shards.wg.Add(1)
+		go func() {
+			defer shards.wg.Done()
+			builder := nodestore.NewBuilder()
+			for batch := range shards.inputs[i] {
+				for _, node := range batch {
+					builder.Add(node)
+				}
+			}
+			shards.stores[i] = builder.Build()
+		}()
+	}
+	return shards
+}
+
+func (s *nodeShards) shardIndex(id osm.NodeID) int {
+	return int(uint64(id) % uint64(len(s.inputs)))
+}
+
+// add queues a node for insertion, it must only be called from one goroutine
+func (s *nodeShards) add(node *osm.Node) {
+	index := s.shardIndex(node.ID)
+	s.batches[index] = append(s.batches[index], node)
+	if len(s.batches[index]) >= nodeBatchSize {
+		s.inputs[index] <- s.batches[index]
+		s.batches[index] = make([]*osm.Node, 0, nodeBatchSize)
+	}
+}
+
+// close hands over the remaining nodes and waits until all shards are built
+func (s *nodeShards) close() {
+	for index, batch := range s.batches {
+		if len(batch) > 0 {
+			s.inputs[index] <- batch
+		}
+		close(s.inputs[index])
+	}
+	s.batches = nil
+	s.wg.Wait()
+}
+
+func (s *nodeShards) Point(id osm.NodeID) (orb.Point, bool) {
+	return s.stores[s.shardIndex(id)].Point(id)
+}
+
+func (s *nodeShards) Tags(id osm.NodeID) osm.Tags {
+	return s.stores[s.shardIndex(id)].Tags(id)
+}
+
+func (s *nodeShards) len() int {
+	total := 0
+	for _, store := range s.stores {
+		total += store.Len()
+	}
+	return total
+}
+
+// merge combines all shards into one store
+func (s *nodeShards) merge() *nodestore.Store {
+	return nodestore.Merge(s.stores...)
+}
@@ -0,0 +1,154 @@
+package ingest
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/jkulzer/platform-router/helpers"
+
+	"github.com/jkulzer/osm"
+)
+
+// Station is a stop area or, for platforms that aren't part of one, all platforms with the same name
+type Station struct {
+	Name string
+	// StopArea is the public_transport=stop_area relation, 0 if the platforms aren't part of one
+	StopArea  osm.RelationID
+	Platforms []osm.ElementID
+	// Lines are the refs of the services stopping at the platforms, or their names if they have no ref
+	Lines []string
+}
+
+// IsPlatform reports if the tags are those of a platform
+func IsPlatform(tags osm.Tags) bool {
+	return tags.Find("railway") == "platform" || tags.Find("public_transport") == "platform"
+}
+
+// Stations returns the named stations that have at least one platform with a service, sorted by name.
+// Only platform ways and relations are used, platform nodes can't be routed from.
+func (d *Dataset) Stations() []Station {
+	var stations []Station
+	inStopArea := make(map[osm.FeatureID]bool)
+
+	for _, relation := range d.Relations {
+		name := relation.Tags.Find("name")
+		if relation.Tags.Find("public_transport") != "stop_area" || name == "" {
+			continue
+		}
+		station := Station{Name: name, StopArea: relation.ID}
+		for _, member := range relation.Members {
+			platformID, exists := d.platformElementID(member.FeatureID())
+			if !exists {
+				continue
+			}
+			inStopArea[member.FeatureID()] = true
+			station.Platforms = append(station.Platforms, platformID)
+		}
+		if d.addLines(&station) {
+			stations = append(stations, station)
+		}
+	}
+
+	// platforms that aren't part of a stop area are grouped by their name like the search does
+	byName := make(map[string]*Station)
+	addPlatform := func(name string, featureID osm.FeatureID, elementID osm.ElementID) {
+		if name == "" || inStopArea[featureID] || len(d.RoutesByMember[featureID]) == 0 {
+			return
+		}
+		station, exists := byName[name]
+		if !exists {
+			station = &Station{Name: name}
+			byName[name] = station
+		}
+		station.Platforms = append(station.Platforms, elementID)
+	}
+	for _, way := range d.Ways {
+		if IsPlatform(way.Tags) {
+			addPlatform(way.Tags.Find("name"), way.FeatureID(), way.ElementID())
+		}
+	}
+	for _, relation := range d.Relations {
+		if IsPlatform(relation.Tags) {
+			addPlatform(relation.Tags.Find("name"), relation.FeatureID(), relation.ElementID())
+		}
+	}
+	for _, station := range byName {
+		d.addLines(station)
+		stations = append(stations, *station)
+	}
+
+	// sorted, so the order doesn't depend on the maps
+	for _, station := range stations {
+		slices.Sort(station.Platforms)
+	}
+	slices.SortFunc(stations, func(a, b Station) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.StopArea, b.StopArea), slices.Compare(a.Platforms, b.Platforms))
+	})
+	return stations
+}
+
+// returns the element ID of the platform way or relation, if the feature is one
+func (d *Dataset) platformElementID(featureID osm.FeatureID) (osm.ElementID, bool) {
+	switch featureID.Type() {
+	case osm.TypeWay:
+		way, exists := d.Ways[featureID.WayID()]
+		if exists && IsPlatform(way.Tags) {
+			return way.ElementID(), true
+		}
+	case osm.TypeRelation:
+		relation, exists := d.Relations[featureID.RelationID()]
+		if exists && IsPlatform(relation.Tags) {
+			return relation.ElementID(), true
+		}
+	}
+	return 0, false
+}
+
+// adds the lines stopping at the platforms of the station and reports if there are any
+func (d *Dataset) addLines(station *Station) bool {
+	for _, platformID := range station.Platforms {
+		for _, route := range d.RoutesByMember[platformID.FeatureID()] {
+			line := route.Tags.Find("ref")
+			if line == "" {
+				line = route.Tags.Find("name")
+			}
+			if line != "" && !slices.Contains(station.Lines, line) {
+				station.Lines = append(station.Lines, line)
+			}
+		}
+	}
+	slices.SortFunc(station.Lines, helpers.CompareNatural)
+	return len(station.Lines) > 0
+}
+
+// MatchStations returns up to limit stations whose name contains the text, ignoring case.
+// Stations whose name or one of its words starts with the text come first, otherwise the order is kept.
+func MatchStations(stations []Station, text string, limit int) []Station {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return nil
+	}
+	var prefixMatches, otherMatches []Station
+	for _, station := range stations {
+		name := strings.ToLower(station.Name)
+		index := strings.Index(name, text)
+		switch {
+		case index == -1:
+			continue
+		case index == 0 || strings.ContainsAny(name[index-1:index], " -/(."):
+			prefixMatches = append(prefixMatches, station)
+		default:
+			otherMatches = append(otherMatches, station)
+		}
+		// enough prefix matches can't be pushed out by later ones
+		if len(prefixMatches) >= limit {
+			break
+		}
+	}
+	matches := append(prefixMatches, otherMatches...)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
@@ -0,0 +1,99 @@
+package ingest
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/jkulzer/osm"
+	"github.com/jkulzer/osm/osmtest"
+)
+
+func TestStations(t *testing.T) {
+	platform := func(id osm.WayID, name string) *osm.Way {
+		return &osm.Way{ID: id, Version: 1, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}}, Tags: osm.Tags{{Key: "railway", Value: "platform"}, {Key: "name", Value: name}}}
+	}
+	route := func(id osm.RelationID, ref string, platforms ...osm.WayID) *osm.Relation {
+		relation := &osm.Relation{ID: id, Tags: osm.Tags{{Key: "type", Value: "route"}, {Key: "route", Value: "subway"}, {Key: "ref", Value: ref}}}
+		for _, platform := range platforms {
+			relation.Members = append(relation.Members, osm.Member{Type: osm.TypeWay, Ref: int64(platform), Role: "platform"})
+		}
+		return relation
+	}
+	objects := osm.Objects{
+		&osm.Node{ID: 1, Lat: 52.5000, Lon: 13.4000},
+		&osm.Node{ID: 2, Lat: 52.5001, Lon: 13.4000},
+		// both platforms of the stop area have different names
+		platform(10, "Alexanderplatz (U2)"),
+		platform(11, "Alexanderplatz (U8)"),
+		// the same name without a stop area
+		platform(12, "Hermannplatz"),
+		platform(13, "Hermannplatz"),
+		// no service stops here
+		platform(14, "Disused"),
+		route(100, "U8", 11, 12),
+		route(101, "U2", 10),
+		route(102, "U7", 13),
+		route(103, "U12", 13),
+		&osm.Relation{ID: 200, Tags: osm.Tags{{Key: "public_transport", Value: "stop_area"}, {Key: "name", Value: "Alexanderplatz"}}, Members: osm.Members{
+			{Type: osm.TypeWay, Ref: 10, Role: "platform"},
+			{Type: osm.TypeWay, Ref: 11, Role: "platform"},
+			{Type: osm.TypeNode, Ref: 1, Role: "stop"},
+		}},
+	}
+	dataset, err := LoadFromScanner(context.Background(), osmtest.NewScanner(objects), Options{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stations := dataset.Stations()
+	if len(stations) != 2 {
+		t.Fatalf("expected 2 stations, got %v", stations)
+	}
+	alexanderplatz := stations[0]
+	if alexanderplatz.Name != "Alexanderplatz" || alexanderplatz.StopArea != 200 {
+		t.Errorf("expected the stop area Alexanderplatz, got %v", alexanderplatz)
+	}
+	if !slices.Equal(alexanderplatz.Platforms, []osm.ElementID{osm.WayID(10).ElementID(1), osm.WayID(11).ElementID(1)}) {
+		t.Errorf("unexpected platforms of Alexanderplatz: %v", alexanderplatz.Platforms)
+	}
+	if !slices.Equal(alexanderplatz.Lines, []string{"U2", "U8"}) {
+		t.Errorf("unexpected lines of Alexanderplatz: %v", alexanderplatz.Lines)
+	}
+	hermannplatz := stations[1]
+	if hermannplatz.Name != "Hermannplatz" || hermannplatz.StopArea != 0 || len(hermannplatz.Platforms) != 2 {
+		t.Errorf("expected both Hermannplatz platforms without a stop area, got %v", hermannplatz)
+	}
+	// U12 comes after U8 like on the signs
+	if !slices.Equal(hermannplatz.Lines, []string{"U7", "U8", "U12"}) {
+		t.Errorf("unexpected lines of Hermannplatz: %v", hermannplatz.Lines)
+	}
+}
+
+func TestMatchStations(t *testing.T) {
+	stations := []Station{{Name: "Alexanderplatz"}, {Name: "Hermannplatz"}, {Name: "S+U Pankow"}, {Name: "Platz der Luftbrücke"}}
+	names := func(stations []Station) []string {
+		var names []string
+		for _, station := range stations {
+			names = append(names, station.Name)
+		}
+		return names
+	}
+	tests := []struct {
+		text     string
+		limit    int
+		expected []string
+	}{
+		// the start of a word comes before the middle of one
+		{"platz", 10, []string{"Platz der Luftbrücke", "Alexanderplatz", "Hermannplatz"}},
+		{"PANK", 10, []string{"S+U Pankow"}},
+		{"platz", 2, []string{"Platz der Luftbrücke", "Alexanderplatz"}},
+		{"  ", 10, nil},
+		{"Kreuzberg", 10, nil},
+	}
+	for _, test := range tests {
+		if matches := names(MatchStations(stations, test.text, test.limit)); !slices.Equal(matches, test.expected) {
+			t.Errorf("expected %v for %q, got %v", test.expected, test.text, matches)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/golang/geo/s2"
 
 	"github.com/jkulzer/platform-router/models"
+	"github.com/jkulzer/platform-router/nodestore"
 
 	"github.com/jkulzer/osm"
 	"github.com/paulmach/orb"
@@ -104,29 +105,29 @@ func radiansToDegrees(rad float64) float64 {
 	return rad * 180.0 / math.Pi
 }
 
-func SetPlatformSpine(ctx models.AppContext, sourceNodes []osm.Node, platformSpines map[osm.ElementID]models.PlatformSpine, trainTracks []orb.Ring, nodes map[osm.NodeID]*osm.Node, elementID osm.ElementID, allClosePoints *[]osm.Node) {
-	log.Debug().Msg("starting setting platform spine for " + fmt.Sprint(elementID))
+// FindPlatformSpine finds the spine of a platform, which is the part of the platform edge next to the tracks.
+// For closed platforms this is the longest run of boundary nodes that lie within one of the track bounds,
+// so passing only an index of the tracks a service uses selects the side of an island platform that service stops at.
+func FindPlatformSpine(ctx models.AppContext, sourceNodes []osm.Node, trainTracks *TrackIndex, nodes *nodestore.Store, elementID osm.ElementID, allClosePoints *[]osm.Node) (models.PlatformSpine, error) {
+	log.Debug().Msg("starting finding platform spine for " + fmt.Sprint(elementID))
 	platformNodeLength := len(sourceNodes)
 	nodeCloseness := make([]bool, platformNodeLength)
 	if platformNodeLength != 0 {
 		if sourceNodes[0].ID == sourceNodes[platformNodeLength-1].ID {
 			for index, node := range sourceNodes {
-				for _, bound := range trainTracks {
-					_, err := IsPointInRectangle(bound, NodeToPoint(*nodes[node.ID]))
-					isCloseToRails, err := IsPointInRectangle(bound, NodeToPoint(*nodes[node.ID]))
-					if err != nil {
-						log.Warn().Msg("Failed to check if platform " + fmt.Sprint(elementID) + " is inside of bound")
-					}
-					if isCloseToRails {
-						log.Debug().Msg("close to rails")
-						nodeCloseness[index] = isCloseToRails
-					} else {
-						if nodeCloseness[index] == true {
-						} else {
-							nodeCloseness[index] = false
-						}
-					}
+				nodePoint, exists := nodes.Point(node.ID)
+				if !exists {
+					log.Warn().Msg("node " + fmt.Sprint(node.ID) + " of platform " + fmt.Sprint(elementID) + " is missing")
+					continue
 				}
+				isCloseToRails, err := trainTracks.ContainsPoint(nodePoint)
+				if err != nil {
+					log.Warn().Msg("Failed to check if platform " + fmt.Sprint(elementID) + " is inside of bound")
+				}
+				if isCloseToRails {
+					log.Debug().Msg("close to rails")
+				}
+				nodeCloseness[index] = isCloseToRails
 			}
 			log.Debug().Msg(fmt.Sprint(nodeCloseness))
 			startingPoint := 0
@@ -138,16 +139,9 @@ func SetPlatformSpine(ctx models.AppContext, sourceNodes []osm.Node, platformSpi
 					log.Debug().Msg("all nodes inside of bounds")
 				}
 			}
-			toMove := nodeCloseness[0:startingPoint]
-			slices.Delete(nodeCloseness, 0, startingPoint)
-			nodeCloseness = append(nodeCloseness, toMove...)
-
-			platformNodes := make([]osm.Node, platformNodeLength)
-			copy(platformNodes, sourceNodes)
-
-			platformNodesToMove := platformNodes[0:startingPoint]
-			slices.Delete(platformNodes, 0, startingPoint)
-			platformNodes = append(platformNodes, platformNodesToMove...)
+			// rotates the ring so that it starts outside of the bounds and no run of close nodes wraps around the end
+			nodeCloseness = slices.Concat(nodeCloseness[startingPoint:], nodeCloseness[:startingPoint])
+			platformNodes := slices.Concat(sourceNodes[startingPoint:], sourceNodes[:startingPoint])
 
 			log.Debug().Msg(fmt.Sprint(nodeCloseness))
 
@@ -159,7 +153,6 @@ func SetPlatformSpine(ctx models.AppContext, sourceNodes []osm.Node, platformSpi
 				if value {
 					// for graphical debug output
 					log.Debug().Msg("node " + fmt.Sprint(platformNodes[index].ElementID()) + " is close")
-					// *allClosePoints = append(*allClosePoints, NodeToPoint(platformNodes[index]))
 					*allClosePoints = append(*allClosePoints, platformNodes[index])
 					if localStart < 0 {
 						localStart = index
@@ -181,33 +174,361 @@ func SetPlatformSpine(ctx models.AppContext, sourceNodes []osm.Node, platformSpi
 					}
 				}
 			}
+			// the last run isn't followed by a node outside of the bounds if it reaches the end of the ring
+			if localStart >= 0 && nodeCloseness[len(nodeCloseness)-1] {
+				if localEnd-localStart > longestEnd-longestStart {
+					longestStart = localStart
+					longestEnd = localEnd
+				}
+			}
 
-			// log.Debug().Msg("platform spine calculation for: " + fmt.Sprint(elementID) + " results in start node " + fmt.Sprint(platformNodes[longestStart]) + " and end node " + fmt.Sprint(platformNodes[longestEnd]))
 			log.Debug().Msg("platform spine calculation for: " + fmt.Sprint(elementID) + " results in start node " + fmt.Sprint(longestStart) + " and end node " + fmt.Sprint(longestEnd))
 
-			if longestStart >= 0 && longestEnd > 0 {
+			if longestStart >= 0 && longestEnd > longestStart {
 				relevantNodes := platformNodes[longestStart : longestEnd+1]
 
 				var spinePoints models.PlatformSpine
-				firstNode := nodes[relevantNodes[0].ID]
-				lastNode := nodes[relevantNodes[len(relevantNodes)-1].ID]
-				firstPoint := NodeToPoint(*firstNode)
-				lastPoint := NodeToPoint(*lastNode)
-				spinePoints.Start = firstPoint
-				spinePoints.End = lastPoint
-				platformSpines[elementID] = spinePoints
+				spinePoints.Start, _ = nodes.Point(relevantNodes[0].ID)
+				spinePoints.End, _ = nodes.Point(relevantNodes[len(relevantNodes)-1].ID)
+				return spinePoints, nil
 			} else {
-				log.Warn().Msg("found no suitable spine for platform with ID " + fmt.Sprint(elementID))
+				return models.PlatformSpine{}, errors.New("found no suitable spine for platform with ID " + fmt.Sprint(elementID))
 			}
 		} else {
-			startNode := NodeToPoint(*nodes[sourceNodes[0].ID])
-			endNode := NodeToPoint(*nodes[sourceNodes[platformNodeLength-1].ID])
 			var currentSpine models.PlatformSpine
-			currentSpine.Start = startNode
-			currentSpine.End = endNode
-			platformSpines[elementID] = currentSpine
+			currentSpine.Start, _ = nodes.Point(sourceNodes[0].ID)
+			currentSpine.End, _ = nodes.Point(sourceNodes[platformNodeLength-1].ID)
+			return currentSpine, nil
 		}
 	} else {
-		log.Warn().Msg("length of source nodes " + fmt.Sprint(elementID) + " is " + fmt.Sprint(platformNodeLength))
+		return models.PlatformSpine{}, errors.New("length of source nodes of platform " + fmt.Sprint(elementID) + " is " + fmt.Sprint(platformNodeLength))
+	}
+}
+
+// TrackBounds returns a rectangle around every segment of the track ways.
+// pad is the distance in meters between the track and the edges of the rectangle.
+func TrackBounds(trackWays []*osm.Way, nodes *nodestore.Store, pad float64) []orb.Ring {
+	var trainTracks []orb.Ring
+	for _, way := range trackWays {
+		for i := 0; i+1 < len(way.Nodes); i++ {
+			start, startExists := nodes.Point(way.Nodes[i].ID)
+			end, endExists := nodes.Point(way.Nodes[i+1].ID)
+			if !startExists || !endExists {
+				continue
+			}
+			trainTracks = append(trainTracks, orb.Ring(GetRotatedBoundWithPad(start, end, pad)))
+		}
+	}
+	return trainTracks
+}
+
+// NodesCentroid returns the average position of the nodes
+func NodesCentroid(platformNodes []osm.Node) orb.Point {
+	var centroid orb.Point
+	if len(platformNodes) == 0 {
+		return centroid
+	}
+	for _, node := range platformNodes {
+		centroid[0] += node.Lon
+		centroid[1] += node.Lat
+	}
+	centroid[0] /= float64(len(platformNodes))
+	centroid[1] /= float64(len(platformNodes))
+	return centroid
+}
+
+// BearingDifference returns the angle to turn from one bearing to another, normalized to the range of -180° to 180°.
+// Positive values are clockwise.
+func BearingDifference(from float64, to float64) float64 {
+	return math.Mod(to-from+540, 360) - 180
+}
+
+// DistanceToWays returns the distance in meters from a point to the closest segment of the ways
+func DistanceToWays(point orb.Point, ways []*osm.Way, nodes *nodestore.Store) float64 {
+	closestDistance := math.Inf(1)
+	for _, way := range ways {
+		for i := 0; i+1 < len(way.Nodes); i++ {
+			start, startExists := nodes.Point(way.Nodes[i].ID)
+			end, endExists := nodes.Point(way.Nodes[i+1].ID)
+			if !startExists || !endExists {
+				continue
+			}
+			closestDistance = math.Min(closestDistance, DistanceToSegment(point, start, end))
+		}
+	}
+	return closestDistance
+}
+
+// RouteTrackWays returns the ways a route relation runs on, in the order the route traverses them.
+// Platforms and other members with a role are skipped since they aren't part of the track.
+func RouteTrackWays(service osm.Relation, ways map[osm.WayID]*osm.Way) []*osm.Way {
+	var trackWays []*osm.Way
+	for _, member := range service.Members {
+		if member.Type != osm.TypeWay || member.Role != "" {
+			continue
+		}
+		way, exists := ways[osm.WayID(member.Ref)]
+		if !exists || len(way.Nodes) < 2 {
+			log.Debug().Msg("route track way " + fmt.Sprint(member.Ref) + " of service " + fmt.Sprint(service.ID) + " is missing or too short")
+			continue
+		}
+		trackWays = append(trackWays, way)
+	}
+	return trackWays
+}
+
+// isTraversedForward checks if the route runs along the way at index i in the order of the way nodes.
+// This is determined by which end of the way connects to the neighbouring ways of the route.
+// The second return value is false if the way isn't connected to any of its neighbours.
+func isTraversedForward(trackWays []*osm.Way, i int) (bool, bool) {
+	way := trackWays[i]
+	first := way.Nodes[0].ID
+	last := way.Nodes[len(way.Nodes)-1].ID
+	if i+1 < len(trackWays) {
+		next := trackWays[i+1]
+		nextFirst := next.Nodes[0].ID
+		nextLast := next.Nodes[len(next.Nodes)-1].ID
+		if last == nextFirst || last == nextLast {
+			return true, true
+		}
+		if first == nextFirst || first == nextLast {
+			return false, true
+		}
+	}
+	if i > 0 {
+		prev := trackWays[i-1]
+		prevFirst := prev.Nodes[0].ID
+		prevLast := prev.Nodes[len(prev.Nodes)-1].ID
+		if first == prevFirst || first == prevLast {
+			return true, true
+		}
+		if last == prevFirst || last == prevLast {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// DistanceToSegment returns the distance in meters from a point to the segment between a and b
+func DistanceToSegment(point orb.Point, a orb.Point, b orb.Point) float64 {
+	return s2.DistanceFromSegment(OrbPointToGeoPoint(point), OrbPointToGeoPoint(a), OrbPointToGeoPoint(b)).Radians() * orb.EarthRadius
+}
+
+// TravelBearingNearPoint finds the track segment of the route closest to the point
+// and returns the bearing in which the route travels along it, as well as the point on the track closest to the given point.
+// Segments further away than maxDistance meters are ignored.
+func TravelBearingNearPoint(point orb.Point, trackWays []*osm.Way, nodes *nodestore.Store, maxDistance float64) (float64, orb.Point, error) {
+	closestDistance := math.Inf(1)
+	var closestStart orb.Point
+	var closestEnd orb.Point
+	for i, way := range trackWays {
+		forward, connected := isTraversedForward(trackWays, i)
+		if !connected {
+			log.Debug().Msg("can't determine direction of travel on way " + fmt.Sprint(way.ID) + " since it isn't connected to the rest of the route")
+			continue
+		}
+		for j := 0; j+1 < len(way.Nodes); j++ {
+			start, startExists := nodes.Point(way.Nodes[j].ID)
+			end, endExists := nodes.Point(way.Nodes[j+1].ID)
+			if !startExists || !endExists {
+				continue
+			}
+			distance := DistanceToSegment(point, start, end)
+			if distance < closestDistance {
+				closestDistance = distance
+				if forward {
+					closestStart, closestEnd = start, end
+				} else {
+					closestStart, closestEnd = end, start
+				}
+			}
+		}
+	}
+	if closestDistance > maxDistance {
+		return 0, orb.Point{}, errors.New("no track segment of the route within " + fmt.Sprint(maxDistance) + "m of " + fmt.Sprint(point))
+	}
+	trackPoint := GeoPointToOrbPoint(s2.Project(OrbPointToGeoPoint(point), OrbPointToGeoPoint(closestStart), OrbPointToGeoPoint(closestEnd)))
+	return geo.Bearing(closestStart, closestEnd), trackPoint, nil
+}
+
+// the S2 cell level the track index is bucketed by, cells on this level are about 70m wide
+const trackIndexCellLevel = 17
+
+// TrackIndex is a spatial index of the bounds around track segments.
+// Every bound is stored in all S2 cells its bounding box overlaps, so proximity checks only need to look at the bounds in the cell of the point.
+type TrackIndex struct {
+	cells  map[s2.CellID][]int
+	bounds []orb.Ring
+	count  int
+}
+
+// NewTrackIndex creates a track index containing the bounds
+func NewTrackIndex(bounds []orb.Ring) *TrackIndex {
+	trackIndex := &TrackIndex{
+		cells: make(map[s2.CellID][]int),
+	}
+	for _, bound := range bounds {
+		trackIndex.Add(bound)
+	}
+	return trackIndex
+}
+
+func boundCovering(bound orb.Ring) s2.CellUnion {
+	rect := s2.RectFromLatLng(s2.LatLngFromDegrees(bound[0].Lat(), bound[0].Lon()))
+	for _, point := range bound[1:] {
+		rect = rect.AddPoint(s2.LatLngFromDegrees(point.Lat(), point.Lon()))
+	}
+	coverer := s2.RegionCoverer{MinLevel: trackIndexCellLevel, MaxLevel: trackIndexCellLevel, MaxCells: 8}
+	return coverer.Covering(rect)
+}
+
+// Add inserts the bound around a track segment into the index and returns a handle to remove it again
+func (ti *TrackIndex) Add(bound orb.Ring) int {
+	boundIndex := len(ti.bounds)
+	ti.bounds = append(ti.bounds, bound)
+	if len(bound) == 0 {
+		return boundIndex
+	}
+	ti.count++
+	for _, cellID := range boundCovering(bound) {
+		ti.cells[cellID] = append(ti.cells[cellID], boundIndex)
+	}
+	return boundIndex
+}
+
+// Remove deletes the bound with the handle returned by Add from the index
+func (ti *TrackIndex) Remove(boundIndex int) {
+	bound := ti.bounds[boundIndex]
+	if len(bound) == 0 {
+		return
+	}
+	for _, cellID := range boundCovering(bound) {
+		ti.cells[cellID] = slices.DeleteFunc(ti.cells[cellID], func(i int) bool { return i == boundIndex })
+		if len(ti.cells[cellID]) == 0 {
+			delete(ti.cells, cellID)
+		}
+	}
+	ti.bounds[boundIndex] = nil
+	ti.count--
+}
+
+// Len returns the number of bounds in the index
+func (ti *TrackIndex) Len() int {
+	return ti.count
+}
+
+// Bounds returns all bounds in the index
+func (ti *TrackIndex) Bounds() []orb.Ring {
+	bounds := make([]orb.Ring, 0, ti.count)
+	for _, bound := range ti.bounds {
+		if len(bound) != 0 {
+			bounds = append(bounds, bound)
+		}
+	}
+	return bounds
+}
+
+// ContainsPoint checks if the point lies within the bound of any track segment in the index
+func (ti *TrackIndex) ContainsPoint(point orb.Point) (bool, error) {
+	cellID := s2.CellIDFromLatLng(s2.LatLngFromDegrees(point.Lat(), point.Lon())).Parent(trackIndexCellLevel)
+	for _, boundIndex := range ti.cells[cellID] {
+		isInside, err := IsPointInRectangle(ti.bounds[boundIndex], point)
+		if err != nil {
+			return false, err
+		}
+		if isInside {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// the S2 level of the cells of the way index, they are about 300 m wide so the surroundings of a station are only a few cells
+const wayIndexCellLevel = 15
+
+// WayIndex is a spatial index of ways.
+// Every way is stored in all S2 cells the bounding boxes of its segments overlap, so ways crossing an area are found even if none of their nodes lies inside it.
+type WayIndex struct {
+	cells    map[s2.CellID][]osm.WayID
+	wayCells map[osm.WayID][]s2.CellID
+}
+
+// NewWayIndex creates an empty way index
+func NewWayIndex() *WayIndex {
+	return &WayIndex{
+		cells:    make(map[s2.CellID][]osm.WayID),
+		wayCells: make(map[osm.WayID][]s2.CellID),
+	}
+}
+
+func wayIndexCovering(rect s2.Rect) s2.CellUnion {
+	coverer := s2.RegionCoverer{MinLevel: wayIndexCellLevel, MaxLevel: wayIndexCellLevel, MaxCells: 8}
+	return coverer.Covering(rect)
+}
+
+// Add inserts the way into the index, replacing it if it is already part of it. Nodes missing from the store are skipped.
+func (wi *WayIndex) Add(way *osm.Way, nodes *nodestore.Store) {
+	wi.Remove(way.ID)
+	cells := make(map[s2.CellID]bool)
+	var previous s2.LatLng
+	hasPrevious := false
+	for _, wayNode := range way.Nodes {
+		point, exists := nodes.Point(wayNode.ID)
+		if !exists {
+			continue
+		}
+		current := s2.LatLngFromDegrees(point.Lat(), point.Lon())
+		rect := s2.RectFromLatLng(current)
+		if hasPrevious {
+			rect = rect.AddPoint(previous)
+		}
+		for _, cellID := range wayIndexCovering(rect) {
+			cells[cellID] = true
+		}
+		previous, hasPrevious = current, true
+	}
+	for cellID := range cells {
+		wi.cells[cellID] = append(wi.cells[cellID], way.ID)
+		wi.wayCells[way.ID] = append(wi.wayCells[way.ID], cellID)
+	}
+}
+
+// Remove deletes the way from the index, ways that aren't part of it are ignored
+func (wi *WayIndex) Remove(id osm.WayID) {
+	for _, cellID := range wi.wayCells[id] {
+		wi.cells[cellID] = slices.DeleteFunc(wi.cells[cellID], func(wayID osm.WayID) bool { return wayID == id })
+		if len(wi.cells[cellID]) == 0 {
+			delete(wi.cells, cellID)
+		}
+	}
+	delete(wi.wayCells, id)
+}
+
+// Contains reports if the way is part of the index
+func (wi *WayIndex) Contains(id osm.WayID) bool {
+	_, exists := wi.wayCells[id]
+	return exists
+}
+
+// Len returns the number of ways in the index
+func (wi *WayIndex) Len() int {
+	return len(wi.wayCells)
+}
+
+// Intersecting returns the ways in the cells overlapping the bound sorted by ID.
+// The cells are larger than the bound, so the ways can still lie outside of it.
+func (wi *WayIndex) Intersecting(bound orb.Bound) []osm.WayID {
+	rect := s2.RectFromLatLng(s2.LatLngFromDegrees(bound.Min.Lat(), bound.Min.Lon())).AddPoint(s2.LatLngFromDegrees(bound.Max.Lat(), bound.Max.Lon()))
+	found := make(map[osm.WayID]bool)
+	var ids []osm.WayID
+	for _, cellID := range wayIndexCovering(rect) {
+		for _, id := range wi.cells[cellID] {
+			if !found[id] {
+				found[id] = true
+				ids = append(ids, id)
+			}
+		}
 	}
+	slices.Sort(ids)
+	return ids
 }
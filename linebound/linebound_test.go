@@ -0,0 +1,253 @@
+package linebound
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/jkulzer/platform-router/nodestore"
+
+	"github.com/jkulzer/osm"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+)
+
+// creates a city sized grid of short track segments and points to check, roughly the extent of Berlin
+func trackGrid() ([]orb.Ring, []orb.Point) {
+	random := rand.New(rand.NewSource(1))
+	var bounds []orb.Ring
+	for i := 0; i < 20000; i++ {
+		start := orb.Point{13.1 + random.Float64()*0.6, 52.35 + random.Float64()*0.3}
+		end := orb.Point{start.Lon() + (random.Float64()-0.5)*0.002, start.Lat() + (random.Float64()-0.5)*0.001}
+		bounds = append(bounds, orb.Ring(GetRotatedBoundWithPad(start, end, 3)))
+	}
+	var points []orb.Point
+	for i := 0; i < 200; i++ {
+		// half of the points are right on a track segment
+		if i%2 == 0 {
+			bound := bounds[random.Intn(len(bounds))]
+			points = append(points, orb.Point{(bound[0].Lon() + bound[2].Lon()) / 2, (bound[0].Lat() + bound[2].Lat()) / 2})
+		} else {
+			points = append(points, orb.Point{13.1 + random.Float64()*0.6, 52.35 + random.Float64()*0.3})
+		}
+	}
+	return bounds, points
+}
+
+func linearContainsPoint(bounds []orb.Ring, point orb.Point) bool {
+	for _, bound := range bounds {
+		if isInside, _ := IsPointInRectangle(bound, point); isInside {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTrackIndexMatchesLinearScan(t *testing.T) {
+	bounds, points := trackGrid()
+	trackIndex := NewTrackIndex(bounds)
+	for _, point := range points {
+		indexed, err := trackIndex.ContainsPoint(point)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if linear := linearContainsPoint(bounds, point); linear != indexed {
+			t.Errorf("point %v: linear scan says %v, index says %v", point, linear, indexed)
+		}
+	}
+}
+
+func BenchmarkTrackProximityLinear(b *testing.B) {
+	bounds, points := trackGrid()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, point := range points {
+			linearContainsPoint(bounds, point)
+		}
+	}
+}
+
+func BenchmarkTrackProximityIndexed(b *testing.B) {
+	bounds, points := trackGrid()
+	trackIndex := NewTrackIndex(bounds)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, point := range points {
+			trackIndex.ContainsPoint(point)
+		}
+	}
+}
+
+func BenchmarkTrackIndexBuild(b *testing.B) {
+	bounds, _ := trackGrid()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewTrackIndex(bounds)
+	}
+}
+
+// a way through the nodes with the given IDs
+func trackWay(id osm.WayID, nodeIDs ...osm.NodeID) *osm.Way {
+	way := &osm.Way{ID: id}
+	for _, nodeID := range nodeIDs {
+		way.Nodes = append(way.Nodes, osm.WayNode{ID: nodeID})
+	}
+	return way
+}
+
+func TestWayIndex(t *testing.T) {
+	builder := nodestore.NewBuilder()
+	points := map[osm.NodeID]orb.Point{1: {13.38, 52.5}, 2: {13.42, 52.5}, 3: {13.5, 52.5}, 4: {13.501, 52.5}}
+	for id, point := range points {
+		builder.Add(&osm.Node{ID: id, Lon: point.Lon(), Lat: point.Lat()})
+	}
+	nodes := builder.Build()
+
+	index := NewWayIndex()
+	// the track crosses the bound without a node inside it, the footway is far away
+	index.Add(&osm.Way{ID: 10, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}}}, nodes)
+	index.Add(&osm.Way{ID: 11, Nodes: osm.WayNodes{{ID: 3}, {ID: 4}}}, nodes)
+	// adding a way again replaces it
+	index.Add(&osm.Way{ID: 11, Nodes: osm.WayNodes{{ID: 3}, {ID: 4}}}, nodes)
+	bound := orb.Bound{Min: orb.Point{13.399, 52.499}, Max: orb.Point{13.401, 52.501}}
+	if ids := index.Intersecting(bound); len(ids) != 1 || ids[0] != 10 || index.Len() != 2 {
+		t.Errorf("expected only the crossing way in %d ways, got %v", index.Len(), ids)
+	}
+
+	index.Remove(10)
+	if ids := index.Intersecting(bound); len(ids) != 0 || index.Contains(10) || !index.Contains(11) {
+		t.Errorf("expected the removed way to be gone, got %v", ids)
+	}
+}
+
+func TestRouteTrackWays(t *testing.T) {
+	ways := map[osm.WayID]*osm.Way{
+		1: trackWay(1, 1, 2),
+		2: trackWay(2, 2, 3),
+		3: trackWay(3, 3),
+		4: trackWay(4, 10, 11),
+	}
+	service := osm.Relation{ID: 100, Members: osm.Members{
+		{Type: osm.TypeNode, Ref: 1, Role: "stop"},
+		{Type: osm.TypeWay, Ref: 4, Role: "platform"},
+		{Type: osm.TypeWay, Ref: 2},
+		// too short and missing ways are skipped
+		{Type: osm.TypeWay, Ref: 3},
+		{Type: osm.TypeWay, Ref: 99},
+		{Type: osm.TypeWay, Ref: 1},
+	}}
+	trackWays := RouteTrackWays(service, ways)
+	if len(trackWays) != 2 || trackWays[0].ID != 2 || trackWays[1].ID != 1 {
+		t.Errorf("expected the ways 2 and 1 in the order of the route, got %v", trackWays)
+	}
+}
+
+func TestIsTraversedForward(t *testing.T) {
+	tests := []struct {
+		name      string
+		trackWays []*osm.Way
+		forward   []bool
+		connected []bool
+	}{
+		{
+			name:      "ways in node order",
+			trackWays: []*osm.Way{trackWay(1, 1, 2), trackWay(2, 2, 3), trackWay(3, 3, 4)},
+			forward:   []bool{true, true, true},
+			connected: []bool{true, true, true},
+		},
+		{
+			name:      "ways against node order",
+			trackWays: []*osm.Way{trackWay(3, 4, 3), trackWay(2, 3, 2), trackWay(1, 2, 1)},
+			forward:   []bool{true, true, true},
+			connected: []bool{true, true, true},
+		},
+		{
+			name:      "route running backwards along the ways",
+			trackWays: []*osm.Way{trackWay(3, 3, 4), trackWay(2, 2, 3), trackWay(1, 1, 2)},
+			forward:   []bool{false, false, false},
+			connected: []bool{true, true, true},
+		},
+		{
+			name:      "mixed directions",
+			trackWays: []*osm.Way{trackWay(1, 1, 2), trackWay(2, 3, 2), trackWay(3, 3, 4)},
+			forward:   []bool{true, false, true},
+			connected: []bool{true, true, true},
+		},
+		{
+			// the way after the gap still gets its direction from the way behind it
+			name:      "gap in the route",
+			trackWays: []*osm.Way{trackWay(1, 1, 2), trackWay(2, 5, 6), trackWay(3, 6, 7)},
+			forward:   []bool{false, true, true},
+			connected: []bool{false, true, true},
+		},
+		{
+			name:      "single way",
+			trackWays: []*osm.Way{trackWay(1, 1, 2)},
+			forward:   []bool{false},
+			connected: []bool{false},
+		},
+	}
+	for _, test := range tests {
+		for i := range test.trackWays {
+			forward, connected := isTraversedForward(test.trackWays, i)
+			if connected != test.connected[i] || (connected && forward != test.forward[i]) {
+				t.Errorf("%s: expected way %d to be forward %v and connected %v, got %v and %v", test.name, i, test.forward[i], test.connected[i], forward, connected)
+			}
+		}
+	}
+}
+
+func TestTravelBearingNearPoint(t *testing.T) {
+	// a route through three nodes, its ways follow the node order
+	route := func(points ...orb.Point) (*nodestore.Store, []*osm.Way) {
+		builder := nodestore.NewBuilder()
+		for i, point := range points {
+			builder.Add(&osm.Node{ID: osm.NodeID(i + 1), Lon: point.Lon(), Lat: point.Lat()})
+		}
+		return builder.Build(), []*osm.Way{trackWay(1, 1, 2), trackWay(2, 2, 3)}
+	}
+	reversed := func(trackWays []*osm.Way) []*osm.Way {
+		return []*osm.Way{trackWays[1], trackWays[0]}
+	}
+	eastNodes, eastWays := route(orb.Point{13.400, 52.5}, orb.Point{13.401, 52.5}, orb.Point{13.402, 52.5})
+	northNodes, northWays := route(orb.Point{13.4, 52.500}, orb.Point{13.4, 52.501}, orb.Point{13.4, 52.502})
+	antimeridianNodes, antimeridianWays := route(orb.Point{179.9995, 0}, orb.Point{180, 0}, orb.Point{-179.9995, 0})
+
+	tests := []struct {
+		name       string
+		point      orb.Point
+		nodes      *nodestore.Store
+		trackWays  []*osm.Way
+		bearing    float64
+		trackPoint orb.Point
+	}{
+		{"east next to the track", orb.Point{13.4015, 52.5001}, eastNodes, eastWays, 90, orb.Point{13.4015, 52.5}},
+		{"west against the way order", orb.Point{13.4015, 52.5001}, eastNodes, reversed(eastWays), 270, orb.Point{13.4015, 52.5}},
+		// points beyond the ends are projected onto the end of the track
+		{"before the start of the track", orb.Point{13.3996, 52.5}, eastNodes, eastWays, 90, orb.Point{13.400, 52.5}},
+		{"past the end of the track", orb.Point{13.4024, 52.5}, eastNodes, eastWays, 90, orb.Point{13.402, 52.5}},
+		{"north", orb.Point{13.4001, 52.5005}, northNodes, northWays, 0, orb.Point{13.4, 52.5005}},
+		{"south", orb.Point{13.4001, 52.5015}, northNodes, reversed(northWays), 180, orb.Point{13.4, 52.5015}},
+		{"east across the antimeridian", orb.Point{-179.9998, 0.0001}, antimeridianNodes, antimeridianWays, 90, orb.Point{-179.9998, 0}},
+	}
+	for _, test := range tests {
+		bearing, trackPoint, err := TravelBearingNearPoint(test.point, test.trackWays, test.nodes, 50)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if math.Abs(BearingDifference(bearing, test.bearing)) > 1 {
+			t.Errorf("%s: expected a bearing of %v, got %v", test.name, test.bearing, bearing)
+		}
+		if distance := geo.Distance(trackPoint, test.trackPoint); distance > 0.5 {
+			t.Errorf("%s: expected the track point %v, got %v which is %vm away", test.name, test.trackPoint, trackPoint, distance)
+		}
+	}
+
+	if _, _, err := TravelBearingNearPoint(orb.Point{13.401, 52.501}, eastWays, eastNodes, 50); err == nil {
+		t.Error("expected no track segment within 50m of a point 110m away")
+	}
+	if _, _, err := TravelBearingNearPoint(orb.Point{13.401, 52.5}, eastWays[:1], eastNodes, 50); err == nil {
+		t.Error("expected no direction of travel on a route with a single way")
+	}
+}
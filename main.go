@@ -1,25 +1,37 @@
 package main
 
 import (
+	"cmp"
 	"github.com/golang/geo/s2"
 
-	"encoding/json"
+	"context"
 	"errors"
+	"flag"
 	"io"
+	"math"
 
-	"context"
 	"fmt"
 	"gonum.org/v1/gonum/graph"
 	"gonum.org/v1/gonum/graph/path"
 	"gonum.org/v1/gonum/graph/simple"
 	"os"
-	"runtime/pprof"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/jkulzer/platform-router/export"
 	"github.com/jkulzer/platform-router/helpers"
+	"github.com/jkulzer/platform-router/ingest"
 	"github.com/jkulzer/platform-router/linebound"
 	"github.com/jkulzer/platform-router/models"
+	"github.com/jkulzer/platform-router/nodestore"
+	"github.com/jkulzer/platform-router/output"
+	"github.com/jkulzer/platform-router/saved"
 	"github.com/jkulzer/platform-router/ui"
 
 	// logging
@@ -30,7 +42,6 @@ import (
 	"github.com/fatih/color"
 
 	"github.com/jkulzer/osm"
-	"github.com/jkulzer/osm/osmpbf"
 	"github.com/paulmach/orb"
 	"github.com/paulmach/orb/geo"
 
@@ -38,7 +49,7 @@ import (
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
-	// "fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/widget"
 )
 
@@ -54,6 +65,9 @@ type PlatformKey struct {
 type WayPlatform struct {
 }
 
+// the maximum distance in meters between a platform edge and the track of a service for the edge to be picked without asking the user
+const maxEdgeTrackDistance = 10
+
 func isStopPosition(tags osm.Tags, name string) bool {
 	return tags.Find("public_transport") == "stop_position" && strings.Contains(tags.Find("name"), name)
 }
@@ -71,49 +85,96 @@ func main() {
 	fmt.Println("Data from:")
 	fmt.Println("© OpenStreetMap contributors: https://openstreetmap.org/copyright")
 
+	ingestOptions := ingest.DefaultOptions()
+	flag.IntVar(&ingestOptions.Workers, "workers", ingestOptions.Workers, "number of goroutines used for loading OSM data")
+	dataPath := flag.String("data", "", "OSM file that is loaded on startup (.osm.pbf, .osm or Overpass .json, optionally .gz or .bz2)")
+	changeFiles := flag.String("changes", "", "comma separated OsmChange files (.osc or .osc.gz) that are applied in order after loading the data")
+	clipBBox := flag.String("bbox", "", "only load data within this bounding box: min lon,min lat,max lon,max lat")
+	clipPolygonPath := flag.String("clip-polygon", "", "only load data within the polygons of this GeoJSON file")
+	clipBuffer := flag.Float64("clip-buffer", ingest.DefaultClipBuffer, "buffer in meters around the bounding box or polygon that is loaded too")
+	sourceFlag := flag.String("source", "", "platform and service to start from, e.g. way/123,456 for the service relation 456 at the platform way 123. The platform edge of a platform relation can follow, e.g. relation/123,456,way/789. Together with -dest this routes without opening a window")
+	destFlag := flag.String("dest", "", "platform and service to transfer to, in the same format as -source")
+	exportPaths := make(map[string]*string)
+	for _, format := range export.Formats {
+		exportPaths[format.Name] = flag.String(strings.ToLower(format.Name), "", "write the transfer as "+format.Name+" to this file, only used with -source and -dest")
+	}
+	stationFlag := flag.String("station", "", "write a report of the transfers between all services at the platforms whose name contains this to the file given with -report, without opening a window")
+	reportPath := flag.String("report", "", "HTML file the station report is written to")
+	sink := &output.Sink{}
+	flag.StringVar(&sink.Dir, "output-dir", "", "write every transfer in all export formats to this directory, nothing is written if it is empty")
+	flag.BoolVar(&sink.Debug, "debug-artifacts", false, "also write the nodes close to the tracks and a CPU profile of each query to the output directory")
+	flag.Parse()
+
+	initialClip, err := clipFromFlags(*clipBBox, *clipPolygonPath, *clipBuffer)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid clip options")
+	}
+	ingestOptions.Clip = initialClip
+
 	ctx := initAppContext()
+	rootCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stopSignals()
 
-	cpuProfile, _ := os.Create("cpuprofile")
-	pprof.StartCPUProfile(cpuProfile)
+	if *stationFlag != "" || *reportPath != "" {
+		if err := runStationReport(rootCtx, *dataPath, *changeFiles, ingestOptions, *stationFlag, *reportPath); err != nil {
+			log.Fatal().Err(err).Msg("station report failed")
+		}
+		return
+	}
+	if *sourceFlag != "" || *destFlag != "" {
+		if err := runHeadless(rootCtx, *dataPath, *changeFiles, ingestOptions, *sourceFlag, *destFlag, exportPaths, sink); err != nil {
+			log.Fatal().Err(err).Msg("routing failed")
+		}
+		return
+	}
 
 	a := app.NewWithID("1")
 	w := a.NewWindow("Platform Routing App")
 	ctx.Window = w
+	ctx.Saved = saved.NewStore(a.Preferences())
+	savedTransfers := ui.NewSavedTransfersWidget(ctx.Saved)
 
-	searchTermEntry := widget.NewEntry()
+	searchTermEntry := ui.NewStationEntry()
 	searchTermEntry.SetPlaceHolder("Enter station name, e.g., 'Warschauer Straße'")
 
 	// file, err := os.Open("berlin-latest.osm.pbf")
 
-	progressBarOsmParsing := widget.NewProgressBar()
-
-	var nodes map[osm.NodeID]*osm.Node
-	var ways map[osm.WayID]*osm.Way
-	var relations map[osm.RelationID]*osm.Relation
-	footWays := mapset.NewSet[osm.NodeID]()
-	var graph *simple.WeightedDirectedGraph
+	loadingProgress := ui.NewLoadingProgressWidget()
+	ingestOptions.Progress = func(progress ingest.Progress) {
+		loadingProgress.Update(progress)
+		ingest.LogProgress(progress)
+	}
 
-	startingProcessing := make(chan bool)
-	doneProcessing := make(chan bool)
+	loadedChan := make(chan loadedDataset)
 
 	// UI setup
-	loadButton := container.NewVBox(progressBarOsmParsing)
+	cancelLoadingButton := ui.NewCancelButton()
+	cancelQueryButton := ui.NewCancelButton()
+	shared := &sharedDataset{cancelQueries: cancelQueryButton.Cancel}
+	loadButton := container.NewVBox(loadingProgress, cancelLoadingButton)
 
 	readerChan := make(chan fyne.URIReadCloser)
-	searchProcessingReader := make(chan fyne.URIReadCloser)
 	errChan := make(chan error)
 
+	clipOptions := ui.NewClipOptionsWidget(w, initialClip)
+
 	loadFileButton := container.NewVBox(widget.NewButton("Load Data", func() {
 		go func() {
 			ui.ShowFilePicker(w, readerChan, errChan)
 		}()
 	}))
+	datasetLabel := widget.NewLabel("No dataset loaded")
+	datasetLabel.Wrapping = fyne.TextWrapWord
+
 	// Layout the UI components
 	mainMenu := container.NewVBox(
 		widget.NewLabel("Platform Routing Application"),
+		datasetLabel,
 		searchTermEntry,
 		loadButton,
+		clipOptions,
 		loadFileButton,
+		savedTransfers,
 	)
 	tabsList := []*container.TabItem{
 		// don't forget t o add it to the container.NewAppTabs function below!!
@@ -130,64 +191,473 @@ func main() {
 
 	w.SetContent(tabsInstance)
 
+	// the first interrupt cancels all running work and closes the app, a second one kills it
 	go func() {
-		// uri, err := storage.ParseURI("file://berlin-latest.osm.pbf")
-		// if err != nil {
-		// 	log.Error().Err(err).Msg("Failed to parse uri")
-		// 	dialog.ShowError(err, w)
-		// }
-
-		reader := <-readerChan
-		log.Debug().Msg("received file reader for initial processing (1/2)")
-		err := <-errChan
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to open OSM file")
-			dialog.ShowError(err, w)
-		}
-		startingProcessing <- true
-		nodes, ways, relations, footWays, graph = processData(reader, ctx)
-		log.Debug().Msg("initial processing done")
-		doneProcessing <- true
-		searchProcessingReader <- reader
+		<-rootCtx.Done()
+		stopSignals()
+		log.Info().Msg("interrupted, cancelling running work")
+		a.Quit()
 	}()
 
+	// a file given on the command line is loaded the same way as one from the file picker, without one the last dataset is loaded again.
+	// There is no cache of the parsed dataset, the file is read and processed again on every start.
+	var startupDataset fyne.URI
+	if *dataPath != "" {
+		path, err := filepath.Abs(*dataPath)
+		if err != nil {
+			path = *dataPath
+		}
+		startupDataset = storage.NewFileURI(path)
+	} else if lastDataset := ctx.Saved.LastDataset(); lastDataset != nil {
+		// it isn't forgotten, the file might be on a drive that isn't mounted right now
+		if readable, err := storage.CanRead(lastDataset); err != nil || !readable {
+			log.Warn().Err(err).Msg("can't read the last dataset " + lastDataset.String())
+			datasetLabel.SetText("The last dataset " + lastDataset.Name() + " can't be read, load another one")
+		} else {
+			log.Info().Msg("loading the last dataset " + lastDataset.String())
+			startupDataset = lastDataset
+		}
+	}
+	if startupDataset != nil {
+		go func() {
+			reader, err := storage.Reader(startupDataset)
+			readerChan <- reader
+			errChan <- err
+		}()
+	}
+
 	go func() {
-		_ = <-startingProcessing
-		for i := 0.0; i <= 0.95; i += 0.01 {
-			time.Sleep(time.Millisecond * 100)
-			progressBarOsmParsing.SetValue(i)
+		// waits until a file could be loaded, the picker can be cancelled or fail and loading can be cancelled too.
+		// It keeps waiting afterwards, the dataset can be changed.
+		var loadedOnce bool
+		for {
+			reader := <-readerChan
+			log.Debug().Msg("received file reader for initial processing")
+			err := <-errChan
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to open OSM file")
+				dialog.ShowError(err, w)
+				continue
+			}
+			// the picker was cancelled
+			if reader == nil {
+				continue
+			}
+			options := ingestOptions
+			options.Clip, err = clipOptions.Clip()
+			if err != nil {
+				log.Error().Err(err).Msg("invalid clip options")
+				dialog.ShowError(err, w)
+				reader.Close()
+				continue
+			}
+
+			// the current dataset stays in use until the new one is loaded, so a query running on it is stopped
+			if loadedOnce {
+				cancelQueryButton.Cancel()
+				ctx.Tabs.DisableIndex(1)
+				ctx.Tabs.DisableIndex(2)
+				ctx.Tabs.Items[0].Content = mainMenu
+				ctx.Tabs.SelectIndex(0)
+				ctx.Tabs.Refresh()
+			}
+			datasetLabel.SetText("Loading " + reader.URI().Name())
+			loadingProgress.Reset()
+
+			loadCtx, stopLoading := cancelLoadingButton.Start(rootCtx)
+			loaded, err := processData(loadCtx, reader, reader.URI().Name(), ctx, options)
+			stopLoading()
+			reader.Close()
+			if err != nil {
+				if rootCtx.Err() != nil {
+					return
+				}
+				loadingProgress.Reset()
+				// gives the memory of the partially loaded data back right away, a country extract can use several gigabytes
+				debug.FreeOSMemory()
+				if loadedOnce {
+					// back to the current dataset
+					loadedChan <- loadedDataset{}
+				} else {
+					datasetLabel.SetText("No dataset loaded")
+				}
+				continue
+			}
+			// the change files from the command line belong to the first dataset
+			if !loadedOnce && *changeFiles != "" {
+				for _, changeFile := range strings.Split(*changeFiles, ",") {
+					if err := loaded.ApplyChangeFile(changeFile); err != nil {
+						log.Err(err).Msg("failed applying change file " + changeFile)
+						dialog.ShowError(err, w)
+					}
+				}
+			}
+			ctx.Saved.SetLastDataset(reader.URI())
+			loadedOnce = true
+			log.Debug().Msg("processing of " + reader.URI().Name() + " done")
+			loadedChan <- loadedDataset{dataset: loaded, name: reader.URI().Name(), fileTime: fileModTime(reader.URI())}
 		}
+	}()
 
+	go func() {
 		// after data parsing is done
-		_ = <-doneProcessing
-		progressBarOsmParsing.SetValue(1)
+		shared.replace(<-loadedChan)
 
-		button := container.NewVBox(widget.NewButton("Process Input File", func() {
+		startQuery := func(station string, findPlatforms func(dataset *ingest.Dataset) map[osm.ElementID]models.PlatformItem) {
 			log.Info().Msg("started processing input data")
 			ctx.Tabs.EnableIndex(1)
 			ctx.Tabs.SelectIndex(1)
 			// Call data parsing function
 			go func() {
-				reader := <-searchProcessingReader
-				log.Debug().Msg("received file reader for further processing (2/2)")
-				servicesAndPlatforms(reader, ctx, nodes, ways, relations, footWays, graph, searchTermEntry.Text)
+				// a new search cancels the previous one, which might still wait for the selection of platforms
+				queryCtx, stopQuery := cancelQueryButton.Start(rootCtx)
+				defer stopQuery()
+				dataset, release := shared.acquire()
+				defer release()
+				servicesAndPlatforms(queryCtx, ctx, cancelQueryButton, dataset.Nodes, dataset.Ways, dataset.Relations, dataset.FootWays, dataset.Graph, dataset.TrainTracks, dataset.MapWays, dataset.PlatformOutlines, dataset.Timestamp, sink, station, func() map[osm.ElementID]models.PlatformItem {
+					return findPlatforms(dataset)
+				})
 			}()
+		}
+		button := container.NewVBox(widget.NewButton("Process Input File", func() {
+			searchTerm := searchTermEntry.Text
+			startQuery(searchTerm, func(dataset *ingest.Dataset) map[osm.ElementID]models.PlatformItem {
+				return searchPlatforms(dataset.Ways, dataset.Relations, dataset.RoutesByMember, searchTerm)
+			})
 		}))
+		// a station picked from the suggestions goes straight to its platforms
+		searchTermEntry.OnSelected = func(station ingest.Station) {
+			startQuery(station.Name, func(dataset *ingest.Dataset) map[osm.ElementID]models.PlatformItem {
+				return platformServices(station.Platforms, dataset.RoutesByMember)
+			})
+		}
+		// a saved transfer skips the platform selection
+		savedTransfers.SetOnRun(func(transfer saved.Transfer) {
+			go func() {
+				queryCtx, stopQuery := cancelQueryButton.Start(rootCtx)
+				defer stopQuery()
+				dataset, release := shared.acquire()
+				defer release()
+				// the platforms or services might not be part of the loaded data
+				source, err := parseSelection(transfer.Source, dataset)
+				if err != nil {
+					log.Err(err).Msg("can't run saved transfer " + transfer.Label)
+					dialog.ShowError(err, w)
+					return
+				}
+				dest, err := parseSelection(transfer.Dest, dataset)
+				if err != nil {
+					log.Err(err).Msg("can't run saved transfer " + transfer.Label)
+					dialog.ShowError(err, w)
+					return
+				}
+				err = calcShortestPath(queryCtx, ctx, cancelQueryButton, dataset.Nodes, dataset.Ways, dataset.Relations, dataset.TrainTracks, dataset.MapWays, dataset.PlatformOutlines, dataset.FootWays, dataset.Graph, dataset.Timestamp, sink, transfer.Station, source, dest)
+				if errors.Is(err, context.Canceled) {
+					showCancelled(ctx, 2)
+				}
+			}()
+		})
+		updateStations := func() {
+			dataset, release := shared.acquire()
+			stations := dataset.Stations()
+			release()
+			log.Info().Msg("found " + fmt.Sprint(len(stations)) + " stations for the suggestions")
+			searchTermEntry.SetStations(stations)
+		}
+		go updateStations()
+		showDatasetInfo := func() {
+			datasetLabel.SetText(shared.describe())
+		}
+
+		changeReaderChan := make(chan fyne.URIReadCloser)
+		changeErrChan := make(chan error)
+		applyChangesButton := widget.NewButton("Apply Changes (.osc)", func() {
+			go func() {
+				ui.ShowFilePicker(w, changeReaderChan, changeErrChan)
+			}()
+		})
+		go func() {
+			for {
+				reader := <-changeReaderChan
+				err := <-changeErrChan
+				if err != nil {
+					log.Error().Err(err).Msg("Failed to open change file")
+					dialog.ShowError(err, w)
+					continue
+				}
+				// the picker was cancelled
+				if reader == nil {
+					continue
+				}
+				shared.change(func(dataset *ingest.Dataset) {
+					applyChanges(ctx, dataset, reader)
+				})
+				updateStations()
+				showDatasetInfo()
+			}
+		}()
+		// loads another file the same way as the first one
+		changeDatasetButton := widget.NewButton("Change dataset", func() {
+			go func() {
+				ui.ShowFilePicker(w, readerChan, errChan)
+			}()
+		})
 
 		viewport1 := container.NewVBox(
 			widget.NewLabel("Platform Routing Application"),
+			datasetLabel,
 			searchTermEntry,
 			button,
+			applyChangesButton,
+			changeDatasetButton,
 			// loadFileButton,
+			savedTransfers,
 		)
-		ctx.Tabs.Items[0].Content = viewport1
+		showDataset := func() {
+			showDatasetInfo()
+			ctx.Tabs.Items[0].Content = viewport1
+			ctx.Tabs.Refresh()
+		}
+		showDataset()
+
+		// a changed dataset replaces the current one, if changing it failed the current one is shown again
+		for loaded := range loadedChan {
+			if loaded.dataset != nil {
+				shared.replace(loaded)
+				go updateStations()
+			}
+			showDataset()
+		}
 	}()
 	w.ShowAndRun()
+}
 
-	pprof.StopCPUProfile()
+// Routes the transfer given on the command line and writes the exports without opening a window
+func runHeadless(cancelCtx context.Context, dataPath string, changeFiles string, options ingest.Options, sourceFlag string, destFlag string, exportPaths map[string]*string, sink *output.Sink) error {
+	if dataPath == "" || sourceFlag == "" || destFlag == "" {
+		return errors.New("routing without a window needs -data, -source and -dest")
+	}
+	dataset, err := loadHeadless(cancelCtx, dataPath, changeFiles, options)
+	if err != nil {
+		return err
+	}
+
+	source, err := parseSelection(sourceFlag, dataset)
+	if err != nil {
+		return err
+	}
+	dest, err := parseSelection(destFlag, dataset)
+	if err != nil {
+		return err
+	}
+	status := func(text string) {
+		log.Debug().Msg(text)
+	}
+	query, err := sink.StartQuery(stationOfPlatform(dataset, source.Platform))
+	if err != nil {
+		return err
+	}
+	transfer, err := computeTransfer(cancelCtx, models.AppContext{}, status, dataset.Nodes, dataset.Ways, dataset.Relations, dataset.TrainTracks, dataset.MapWays, dataset.FootWays, dataset.Graph, dataset.Timestamp, source, dest)
+	if err != nil {
+		query.Abort()
+		return err
+	}
+	artifacts, err := query.Finish(transfer)
+	if err != nil {
+		return err
+	}
+
+	for _, instruction := range export.Instructions(transfer) {
+		fmt.Println(instruction)
+	}
+	for _, line := range export.Summary(transfer) {
+		fmt.Println(line)
+	}
+	for _, format := range export.Formats {
+		path := *exportPaths[format.Name]
+		if path == "" {
+			continue
+		}
+		if err := writeExport(path, format, transfer); err != nil {
+			return err
+		}
+		log.Info().Msg("wrote " + format.Name + " to " + path)
+	}
+	for _, artifact := range artifacts {
+		fmt.Println(artifact.Kind + ": " + artifact.Path)
+	}
+	return nil
 }
 
-func processData(file io.Reader, ctx models.AppContext) (map[osm.NodeID]*osm.Node, map[osm.WayID]*osm.Way, map[osm.RelationID]*osm.Relation, mapset.Set[osm.NodeID], *simple.WeightedDirectedGraph) {
+// returns the name of the station the platform belongs to, empty if it isn't part of any
+func stationOfPlatform(dataset *ingest.Dataset, platform osm.ElementID) string {
+	for _, station := range dataset.Stations() {
+		for _, stationPlatform := range station.Platforms {
+			if stationPlatform.FeatureID() == platform.FeatureID() {
+				return station.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Writes the report of all transfers at the station given on the command line without opening a window
+func runStationReport(cancelCtx context.Context, dataPath string, changeFiles string, options ingest.Options, station string, reportPath string) error {
+	if dataPath == "" || station == "" || reportPath == "" {
+		return errors.New("a station report needs -data, -station and -report")
+	}
+	dataset, err := loadHeadless(cancelCtx, dataPath, changeFiles, options)
+	if err != nil {
+		return err
+	}
+	status := func(text string) {
+		log.Debug().Msg(text)
+	}
+	platforms := searchPlatforms(dataset.Ways, dataset.Relations, dataset.RoutesByMember, station)
+	entries, err := stationReport(cancelCtx, status, dataset.Nodes, dataset.Ways, dataset.Relations, dataset.TrainTracks, dataset.MapWays, dataset.FootWays, dataset.Graph, dataset.Timestamp, platforms)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return errors.New("there are no two services at the platforms of " + station)
+	}
+
+	file, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	if err := export.WriteReport(file, station, entries, time.Now()); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	log.Info().Msg("wrote the report of " + fmt.Sprint(len(entries)) + " transfers to " + reportPath)
+	return nil
+}
+
+// Loads the data and applies the change files for the modes without a window
+func loadHeadless(cancelCtx context.Context, dataPath string, changeFiles string, options ingest.Options) (*ingest.Dataset, error) {
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	options.Progress = ingest.LogProgress
+	dataset, err := ingest.Load(cancelCtx, file, filepath.Base(dataPath), options)
+	if err != nil {
+		return nil, err
+	}
+	if changeFiles != "" {
+		for _, changeFile := range strings.Split(changeFiles, ",") {
+			if err := dataset.ApplyChangeFile(changeFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return dataset, nil
+}
+
+// Parses a platform and service like way/123,456. The service can also be given as relation/456.
+// The platform edge to use can follow as third part, e.g. relation/123,456,way/789.
+func parseSelection(selection string, dataset *ingest.Dataset) (models.PlatformAndServiceSelection, error) {
+	parts := strings.Split(selection, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return models.PlatformAndServiceSelection{}, errors.New("selection " + selection + " needs a platform and a service separated by a comma, optionally followed by a platform edge")
+	}
+	platformString, serviceString := parts[0], parts[1]
+	platformID, err := osm.ParseFeatureID(platformString)
+	if err != nil {
+		return models.PlatformAndServiceSelection{}, errors.New("invalid platform " + platformString + ": " + err.Error())
+	}
+	serviceID, err := strconv.ParseInt(strings.TrimPrefix(serviceString, "relation/"), 10, 64)
+	if err != nil {
+		return models.PlatformAndServiceSelection{}, errors.New("invalid service " + serviceString + ": " + err.Error())
+	}
+	if _, exists := dataset.Relations[osm.RelationID(serviceID)]; !exists {
+		return models.PlatformAndServiceSelection{}, errors.New("service relation " + fmt.Sprint(serviceID) + " isn't part of the data")
+	}
+
+	// the element ID includes the version, which has to match the one of the loaded platform
+	var platform osm.ElementID
+	switch platformID.Type() {
+	case osm.TypeWay:
+		way, exists := dataset.Ways[platformID.WayID()]
+		if !exists {
+			return models.PlatformAndServiceSelection{}, errors.New("platform " + platformString + " isn't part of the data")
+		}
+		platform = way.ElementID()
+	case osm.TypeRelation:
+		relation, exists := dataset.Relations[platformID.RelationID()]
+		if !exists {
+			return models.PlatformAndServiceSelection{}, errors.New("platform " + platformString + " isn't part of the data")
+		}
+		platform = relation.ElementID()
+	default:
+		return models.PlatformAndServiceSelection{}, errors.New("platform " + platformString + " has to be a way or relation")
+	}
+	parsed := models.PlatformAndServiceSelection{Platform: platform, Service: osm.RelationID(serviceID)}
+
+	if len(parts) == 3 {
+		edgeID, err := strconv.ParseInt(strings.TrimPrefix(parts[2], "way/"), 10, 64)
+		if err != nil {
+			return models.PlatformAndServiceSelection{}, errors.New("invalid platform edge " + parts[2] + ": " + err.Error())
+		}
+		parsed.Edge = osm.WayID(edgeID)
+	}
+	return parsed, nil
+}
+
+// Formats a selection the way parseSelection reads it
+func formatSelection(selection models.PlatformAndServiceSelection) string {
+	formatted := selection.Platform.FeatureID().String() + "," + fmt.Sprint(selection.Service)
+	if selection.Edge != 0 {
+		formatted += "," + selection.Edge.FeatureID().String()
+	}
+	return formatted
+}
+
+// Writes the transfer to a file in the given format
+func writeExport(path string, format export.Format, transfer models.Transfer) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := format.Write(file, transfer); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Creates the clip given on the command line, nil if no region is given
+func clipFromFlags(bbox string, polygonPath string, buffer float64) (*ingest.Clip, error) {
+	switch {
+	case bbox != "" && polygonPath != "":
+		return nil, errors.New("-bbox and -clip-polygon can't be used together")
+	case bbox != "":
+		bound, err := ingest.ParseBBox(bbox)
+		if err != nil {
+			return nil, err
+		}
+		return ingest.NewClip(bound, buffer)
+	case polygonPath != "":
+		file, err := os.Open(polygonPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		polygon, err := ingest.ReadClipPolygon(file)
+		if err != nil {
+			return nil, err
+		}
+		return ingest.NewClip(polygon, buffer)
+	}
+	return nil, nil
+}
+
+func processData(cancelCtx context.Context, file io.Reader, name string, ctx models.AppContext, options ingest.Options) (*ingest.Dataset, error) {
 
 	log.Info().Msg("started processing data")
 	// UI
@@ -196,218 +666,147 @@ func processData(file io.Reader, ctx models.AppContext) (map[osm.NodeID]*osm.Nod
 	loadingContainer := container.NewVBox(infiniteLoadingBar)
 	ctx.Tabs.Items[1].Content = loadingContainer
 
-	nodes := make(map[osm.NodeID]*osm.Node)
-	ways := make(map[osm.WayID]*osm.Way)
-	relations := make(map[osm.RelationID]*osm.Relation)
-	footWays := mapset.NewSet[osm.NodeID]()
-
-	g := simple.NewWeightedDirectedGraph(1, 0)
-
-	// Create a PBF reader
-	scanner := osmpbf.New(context.Background(), file, 4)
-
-	// Scan and populate the relations map
-	for scanner.Scan() {
-		// Get the next OSM object
-		obj := scanner.Object()
-
-		switch v := obj.(type) {
-		case *osm.Node:
-			nodes[v.ID] = v
-			g.AddNode(simple.Node(v.ID))
-		case *osm.Way:
-			ways[v.ID] = v
-			// iterates through every node on every way
-			nodeListLength := len(v.Nodes)
-			for i, node := range v.Nodes {
-
-				// creates an edge for every segment of the way
-				/*
-					If this is on the last node, there are no more segments to create
-					(since the number of edges in a series of edges is node count - 1)
-					and the edge creation must be skipped (otherwise array out of bounds)
-				*/
-				if i+1 != nodeListLength {
-					if v.Tags.Find("highway") == "footway" || v.Tags.Find("highway") == "steps" {
-						thisNode := nodes[v.Nodes[i].ID]
-						nextNode := nodes[v.Nodes[i+1].ID]
-						nodeDistance := geo.Distance(linebound.NodeToPoint(*thisNode), linebound.NodeToPoint(*nextNode))
-						// disable routing through elevators
-						if thisNode.Tags.Find("highway") == "elevator" || nextNode.Tags.Find("highway") == "elevator" {
-						} else {
-
-							// TODO: Add very high penalty for walking in the wrong direction of an escalator
-
-							// only allow walking along escalators the right direction
-							if v.Tags.Find("conveying") != "" && v.Tags.Find("conveying") == "forward" {
-								g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(v.Nodes[i].ID), simple.Node(v.Nodes[i+1].ID), nodeDistance*0.5))
-							} else if v.Tags.Find("conveying") != "" && v.Tags.Find("conveying") == "backward" {
-								g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(v.Nodes[i+1].ID), simple.Node(v.Nodes[i].ID), nodeDistance*0.5))
-
-							} else {
-								// if it is only a basic walkway
-								g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(v.Nodes[i].ID), simple.Node(v.Nodes[i+1].ID), nodeDistance))
-								g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(v.Nodes[i+1].ID), simple.Node(v.Nodes[i].ID), nodeDistance))
-							}
-						}
-					}
-				}
-				// checks if the way is a footpath
-				if v.Tags.Find("highway") == "footway" {
-					footWays.Add(node.ID)
-				}
-			}
-		case *osm.Relation:
-			relations[v.ID] = v
-		default:
-			// Handle other OSM object types if needed
-		}
+	dataset, err := ingest.Load(cancelCtx, file, name, options)
+	if errors.Is(err, context.Canceled) {
+		log.Info().Msg("loading " + name + " was cancelled")
+		return nil, err
 	}
-	// Handle any errors that occurred during scanning
-	if err := scanner.Err(); err != nil {
-		log.Err(err).Msg("Error reading OSM PBF file: %v")
+	if err != nil {
+		log.Err(err).Msg("Error reading OSM file " + name)
 		dialog.ShowError(err, ctx.Window)
+		return nil, err
 	}
 	log.Info().Msg("done processing data")
 
-	return nodes, ways, relations, footWays, g
+	return dataset, nil
+}
+
+// sharedDataset is the dataset the window works on. Queries hold the read lock while they use it,
+// changes are applied with the write lock once the running queries were cancelled and released it.
+type sharedDataset struct {
+	mutex  sync.RWMutex
+	loaded loadedDataset
+	// cancels the running queries, some of them wait for the user and would hold the lock for a long time
+	cancelQueries func()
+}
+
+// acquire returns the dataset, it isn't changed until release is called
+func (s *sharedDataset) acquire() (*ingest.Dataset, func()) {
+	s.mutex.RLock()
+	return s.loaded.dataset, s.mutex.RUnlock
+}
+
+// change cancels the running queries and changes the dataset once they are done.
+// It blocks until then, so it mustn't be called from the UI.
+func (s *sharedDataset) change(change func(dataset *ingest.Dataset)) {
+	s.cancelQueries()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	change(s.loaded.dataset)
+}
+
+// replace cancels the running queries and replaces the dataset once they are done, like change
+func (s *sharedDataset) replace(loaded loadedDataset) {
+	s.cancelQueries()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.loaded = loaded
+}
+
+// describe returns the description of the dataset for the first tab
+func (s *sharedDataset) describe() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return describeDataset(s.loaded.name, s.loaded.fileTime, s.loaded.dataset.Timestamp)
+}
+
+// a dataset that finished loading and the file it was loaded from
+type loadedDataset struct {
+	dataset  *ingest.Dataset
+	name     string
+	fileTime time.Time
+}
+
+// Returns when the file was modified, zero if it isn't a local file
+func fileModTime(uri fyne.URI) time.Time {
+	if uri.Scheme() != "file" {
+		return time.Time{}
+	}
+	info, err := os.Stat(uri.Path())
+	if err != nil {
+		log.Warn().Err(err).Msg("can't determine when " + uri.Path() + " was modified")
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Describes the loaded dataset for the first tab
+func describeDataset(name string, fileTime time.Time, dataTimestamp time.Time) string {
+	description := "Dataset " + name
+	if !fileTime.IsZero() {
+		description += ", file from " + fileTime.Format("2006-01-02 15:04")
+	}
+	if dataTimestamp.IsZero() {
+		description += ", the data has no OSM timestamps"
+	} else {
+		description += ", OSM data as of " + dataTimestamp.UTC().Format("2006-01-02 15:04") + " UTC"
+	}
+	return description
+}
+
+// Applies an OsmChange file picked by the user to the loaded dataset
+func applyChanges(ctx models.AppContext, dataset *ingest.Dataset, reader fyne.URIReadCloser) {
+	defer reader.Close()
+	changes, err := ingest.ReadChanges(reader)
+	if err != nil {
+		log.Err(err).Msg("failed reading change file " + reader.URI().Name())
+		dialog.ShowError(err, ctx.Window)
+		return
+	}
+	sequence, err := ingest.SequenceNumberFromPath(reader.URI().Path())
+	if err != nil {
+		log.Warn().Err(err).Msg("applying changes without replication sequence number")
+	}
+	if err := dataset.ApplyChanges(changes, sequence); err != nil {
+		log.Err(err).Msg("failed applying change file " + reader.URI().Name())
+		dialog.ShowError(err, ctx.Window)
+		return
+	}
+	dialog.ShowInformation("Changes applied", "Applied "+reader.URI().Name()+", the data is at replication sequence "+fmt.Sprint(dataset.ReplicationSequence), ctx.Window)
 }
 
 func servicesAndPlatforms(
-	file io.Reader,
+	cancelCtx context.Context,
 	ctx models.AppContext,
-	nodes map[osm.NodeID]*osm.Node,
+	cancelButton *ui.CancelButton,
+	nodes *nodestore.Store,
 	ways map[osm.WayID]*osm.Way,
 	relations map[osm.RelationID]*osm.Relation,
 	footWays mapset.Set[osm.NodeID],
 	g *simple.WeightedDirectedGraph,
-	searchTerm string,
+	trainTracks *linebound.TrackIndex,
+	mapWays *linebound.WayIndex,
+	platformOutlines mapset.Set[osm.WayID],
+	dataTimestamp time.Time,
+	sink *output.Sink,
+	station string,
+	findPlatforms func() map[osm.ElementID]models.PlatformItem,
 ) {
 	infiniteProgress := widget.NewProgressBarInfinite()
 	infiniteProgress.Start()
-	ctx.Tabs.Items[1].Content = container.NewCenter(infiniteProgress)
+	ctx.Tabs.Items[1].Content = container.NewCenter(container.NewVBox(infiniteProgress, cancelButton))
 	ctx.Tabs.DisableIndex(2)
 
-	platformWays := make(map[osm.WayID]*osm.Way)
-	platformRelations := make(map[osm.RelationID]*osm.Relation)
-	relevantPlatformWays := mapset.NewSet[*osm.Way]()
-	relevantPlatformRelations := mapset.NewSet[*osm.Relation]()
-
-	platforms := make(map[osm.ElementID]models.PlatformItem)
-
-	routes := make(map[osm.RelationID]*osm.Relation)
-	var trainTracks []orb.Ring
-
 	parseStart := time.Now()
 
 	elapsed := time.Since(parseStart)
 	log.Printf("Parsing took %s", elapsed)
-	searchStart := time.Now()
-
-	// Filter ways for platforms and paths
-	for _, v := range ways {
-
-		// platform proximity calculation
-		railwayTag := v.Tags.Find("railway")
-		validRailwayTags := map[string]bool{
-			"rail":         true,
-			"light_rail":   true,
-			"tram":         true,
-			"subway":       true,
-			"narrow_gauge": true,
-			"monorail":     true,
-		}
-		if validRailwayTags[railwayTag] {
-			// for the first run, there's no previous node, therefore the firstRun variables is true
-			var prevPoint orb.Point
-			firstRun := true
-			for _, node := range v.Nodes {
-				if firstRun != true {
-					var point orb.Point
-					point[0] = nodes[node.ID].Lon
-					point[1] = nodes[node.ID].Lat
-					// the third argumenti is how big the bound is around the line
-					localBound := orb.Ring(linebound.GetRotatedBoundWithPad(prevPoint, point, 3))
-					trainTracks = append(trainTracks, localBound)
-					prevPoint = point
-				} else {
-					var point orb.Point
-					point[0] = nodes[node.ID].Lon
-					point[1] = nodes[node.ID].Lat
-					prevPoint = point
-					firstRun = false
-				}
-			}
-		}
-
-		if (v.Tags.Find("railway") == "platform" || v.Tags.Find("public_transport") == "platform") && strings.Contains(v.Tags.Find("name"), searchTerm) {
-			platformWays[v.ID] = v
-			platforms[v.ElementID()] = models.PlatformItem{
-				ElementID: v.ElementID(),
-			}
-		}
-	}
-
-	// Collect routes from relations
-	for _, v := range relations {
-		if v.Tags.Find("type") == "route" {
-			routes[v.ID] = v
-		}
-		if (v.Tags.Find("railway") == "platform" || v.Tags.Find("public_transport") == "platform") && strings.Contains(v.Tags.Find("name"), searchTerm) {
-			platformRelations[v.ID] = v
-			platforms[v.ElementID()] = models.PlatformItem{
-				ElementID: v.ElementID(),
-			}
-		}
-	}
-	elapsed = time.Since(searchStart)
-	log.Printf("Search took %s", elapsed)
-
-	// ==================================
-	// Match stop positions with services
-	// ==================================
-	matchingStart := time.Now()
-
-	// iterates through all routes in the entire city
-	for _, route := range routes {
-
-		// iterates through every node that is in a given route
-		for _, routeMember := range route.Members {
-			// iterates through every platform
-			for platformID, platform := range platformWays {
-
-				// checks if the ID of the platform matches
-				if int64(platformID) == routeMember.Ref {
-					relevantPlatformWays.Add(platform)
 
-					elementID := platform.ElementID()
-					currentPlatform := platforms[elementID]
-					currentPlatform.Services = append(currentPlatform.Services, route)
-					platforms[elementID] = currentPlatform
-
-				}
-			}
-			for platformID, platform := range platformRelations {
-				if routeMember.Type == "relation" {
-
-					relationID, err := routeMember.ElementID().RelationID()
-					if err != nil {
-						log.Err(err).Msg("determining RelationID of platform " + fmt.Sprint(platformID) + " failed since it is not of type relation")
-					}
-					if platformID == relationID {
-						relevantPlatformRelations.Add(platform)
-
-						elementID := platform.ElementID()
-						currentPlatform := platforms[elementID]
-						currentPlatform.Services = append(currentPlatform.Services, route)
-						platforms[elementID] = currentPlatform
-					}
-				}
-			}
-		}
+	platforms := findPlatforms()
+	if cancelCtx.Err() != nil {
+		showCancelled(ctx, 1)
+		return
 	}
-	elapsed = time.Since(matchingStart)
-	log.Printf("Matching took %s", elapsed)
 
 	platformData := color.New(color.Bold, color.FgWhite).PrintlnFunc()
 
@@ -416,7 +815,14 @@ func servicesAndPlatforms(
 		Relations: relations,
 	}
 
-	for platformKey, genericPlatform := range platforms {
+	// sorted, so the platforms are printed in the same order on every run
+	var platformKeys []osm.ElementID
+	for platformKey := range platforms {
+		platformKeys = append(platformKeys, platformKey)
+	}
+	slices.Sort(platformKeys)
+	for _, platformKey := range platformKeys {
+		genericPlatform := platforms[platformKey]
 		platformType, err := platformKey.Type()
 		if err != nil {
 			log.Err(err).Msg("determining type of platform " + fmt.Sprint(genericPlatform.ElementID) + " failed")
@@ -427,7 +833,12 @@ func servicesAndPlatforms(
 			if err != nil {
 				log.Err(err).Msg("determining WayID of platform " + fmt.Sprint(genericPlatform.ElementID) + " failed since it is not of type relation")
 			}
-			data := "Platform " + platformWays[wayID].Tags.Find("name") + " with ID " + fmt.Sprint(wayID) + " and type " + fmt.Sprint(platformType) + " has services:"
+			way, exists := ways[wayID]
+			if !exists {
+				log.Warn().Msg("platform " + fmt.Sprint(platformKey) + " is outside the loaded data")
+				continue
+			}
+			data := "Platform " + way.Tags.Find("name") + " with ID " + fmt.Sprint(wayID) + " and type " + fmt.Sprint(platformType) + " has services:"
 			platformData(strings.Repeat("=", len(data)))
 			platformData(data)
 			platformData(strings.Repeat("=", len(data)))
@@ -436,7 +847,12 @@ func servicesAndPlatforms(
 			if err != nil {
 				log.Err(err).Msg("determining RelationID of platform " + fmt.Sprint(genericPlatform.ElementID) + " failed since it is not of type relation")
 			}
-			data := "Platform " + platformRelations[relationID].Tags.Find("name") + " with ID " + fmt.Sprint(relationID) + " and type " + fmt.Sprint(platformType) + " has services:"
+			relation, exists := relations[relationID]
+			if !exists {
+				log.Warn().Msg("platform " + fmt.Sprint(platformKey) + " is outside the loaded data")
+				continue
+			}
+			data := "Platform " + relation.Tags.Find("name") + " with ID " + fmt.Sprint(relationID) + " and type " + fmt.Sprint(platformType) + " has services:"
 			platformData(strings.Repeat("=", len(data)))
 			platformData(data)
 			platformData(strings.Repeat("=", len(data)))
@@ -466,57 +882,324 @@ func servicesAndPlatforms(
 		userPlatformList = currentList
 	}
 
-	platformUIList := ui.NewPlatformSelector(userPlatformList)
-	platformUIList.SourcePlatformChan = make(chan models.PlatformAndServiceSelection)
-	platformUIList.DestPlatformChan = make(chan models.PlatformAndServiceSelection)
+	platformUIList := ui.NewPlatformSelector(userPlatformList)
+	platformUIList.SourcePlatformChan = make(chan models.PlatformAndServiceSelection)
+	platformUIList.DestPlatformChan = make(chan models.PlatformAndServiceSelection)
+	platformUIList.Done = cancelCtx.Done()
+
+	// the report is computed by the query, so the dataset isn't changed while it is used
+	reportRequests := make(chan struct{})
+	reportButton := widget.NewButton("Station report", func() {
+		go func() {
+			select {
+			case reportRequests <- struct{}{}:
+			case <-cancelCtx.Done():
+			}
+		}()
+	})
+	ctx.Tabs.Items[1].Content = container.NewBorder(reportButton, nil, nil, nil, platformUIList)
+
+	var sourcePlatformID models.PlatformAndServiceSelection
+	var destPlatformID models.PlatformAndServiceSelection
+waitForSelection:
+	for {
+		select {
+		case sourcePlatformID = <-platformUIList.SourcePlatformChan:
+			destPlatformID = <-platformUIList.DestPlatformChan
+			break waitForSelection
+		case <-reportRequests:
+			showStationReport(cancelCtx, ctx, station, nodes, ways, relations, trainTracks, mapWays, footWays, g, dataTimestamp, platforms)
+		case <-cancelCtx.Done():
+			log.Info().Msg("platform selection was cancelled by a new search")
+			return
+		}
+	}
+	// the query ends with the transfer, a report can't be computed by it anymore
+	reportButton.Disable()
+
+	err := calcShortestPath(cancelCtx, ctx, cancelButton, nodes, ways, relations, trainTracks, mapWays, platformOutlines, footWays, g, dataTimestamp, sink, station, sourcePlatformID, destPlatformID)
+	if errors.Is(err, context.Canceled) {
+		showCancelled(ctx, 2)
+	}
+}
+
+// Finds the platforms whose name contains the search term and the services stopping at them
+func searchPlatforms(
+	ways map[osm.WayID]*osm.Way,
+	relations map[osm.RelationID]*osm.Relation,
+	routesByMember map[osm.FeatureID][]*osm.Relation,
+	searchTerm string,
+) map[osm.ElementID]models.PlatformItem {
+	var platformIDs []osm.ElementID
+
+	searchStart := time.Now()
+
+	// Filter ways for platforms and paths
+	for _, v := range ways {
+		if ingest.IsPlatform(v.Tags) && strings.Contains(v.Tags.Find("name"), searchTerm) {
+			platformIDs = append(platformIDs, v.ElementID())
+		}
+	}
+
+	// Filter relations for platforms
+	for _, v := range relations {
+		if ingest.IsPlatform(v.Tags) && strings.Contains(v.Tags.Find("name"), searchTerm) {
+			platformIDs = append(platformIDs, v.ElementID())
+		}
+	}
+	elapsed := time.Since(searchStart)
+	log.Printf("Search took %s", elapsed)
+
+	return platformServices(platformIDs, routesByMember)
+}
+
+// Looks up the services stopping at the platforms
+func platformServices(platformIDs []osm.ElementID, routesByMember map[osm.FeatureID][]*osm.Relation) map[osm.ElementID]models.PlatformItem {
+	// ==================================
+	// Match stop positions with services
+	// ==================================
+	matchingStart := time.Now()
+
+	platforms := make(map[osm.ElementID]models.PlatformItem)
+	for _, platformID := range platformIDs {
+		platforms[platformID] = models.PlatformItem{
+			ElementID: platformID,
+			// the index is shared, so the services are copied
+			Services: slices.Clone(routesByMember[platformID.FeatureID()]),
+		}
+	}
+	elapsed := time.Since(matchingStart)
+	log.Printf("Matching took %s", elapsed)
+	return platforms
+}
+
+// Computes the station report with a progress dialog and asks where to save it afterwards
+func showStationReport(
+	cancelCtx context.Context,
+	ctx models.AppContext,
+	station string,
+	nodes *nodestore.Store,
+	ways map[osm.WayID]*osm.Way,
+	relations map[osm.RelationID]*osm.Relation,
+	trainTracks *linebound.TrackIndex,
+	mapWays *linebound.WayIndex,
+	footWays mapset.Set[osm.NodeID],
+	g *simple.WeightedDirectedGraph,
+	dataTimestamp time.Time,
+	platforms map[osm.ElementID]models.PlatformItem,
+) {
+	reportCtx, stopReport := context.WithCancel(cancelCtx)
+	defer stopReport()
+
+	loadingContainer := ui.NewLoadingScreenWithTextWidget()
+	progressDialog := dialog.NewCustom("Station report", "Cancel", loadingContainer, ctx.Window)
+	// closing the dialog with the button cancels the report
+	progressDialog.SetOnClosed(stopReport)
+	progressDialog.Show()
 
-	ctx.Tabs.Items[1].Content = platformUIList
+	entries, err := stationReport(reportCtx, loadingContainer.SetText, nodes, ways, relations, trainTracks, mapWays, footWays, g, dataTimestamp, platforms)
+	progressDialog.Hide()
+	if err != nil {
+		log.Info().Msg("station report was cancelled")
+		return
+	}
+	if len(entries) == 0 {
+		dialog.ShowInformation("Station report", "There are no two services at the platforms of "+station+".", ctx.Window)
+		return
+	}
+	ui.ShowReportDialog(ctx.Window, station, entries)
+}
 
-	sourcePlatformID := <-platformUIList.SourcePlatformChan
-	destPlatformID := <-platformUIList.DestPlatformChan
+// Computes the transfer between every two services stopping at the platforms, in both directions.
+// A service stopping at several of the platforms is only taken at the first one.
+// Transfers that can't be computed are part of the report with their error, only cancelling returns an error.
+func stationReport(
+	cancelCtx context.Context,
+	status func(string),
+	nodes *nodestore.Store,
+	ways map[osm.WayID]*osm.Way,
+	relations map[osm.RelationID]*osm.Relation,
+	trainTracks *linebound.TrackIndex,
+	mapWays *linebound.WayIndex,
+	footWays mapset.Set[osm.NodeID],
+	g *simple.WeightedDirectedGraph,
+	dataTimestamp time.Time,
+	platforms map[osm.ElementID]models.PlatformItem,
+) ([]export.ReportEntry, error) {
+	// sorted, so the report is the same every time
+	platformIDs := make([]osm.ElementID, 0, len(platforms))
+	for platformID := range platforms {
+		platformIDs = append(platformIDs, platformID)
+	}
+	slices.Sort(platformIDs)
+
+	var selections []models.PlatformAndServiceSelection
+	serviceNames := make(map[osm.RelationID]string)
+	for _, platformID := range platformIDs {
+		services := slices.Clone(platforms[platformID].Services)
+		slices.SortFunc(services, func(a, b *osm.Relation) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+		for _, service := range services {
+			if _, exists := serviceNames[service.ID]; exists {
+				continue
+			}
+			serviceNames[service.ID] = reportServiceName(service)
+			selections = append(selections, models.PlatformAndServiceSelection{Platform: platformID, Service: service.ID})
+		}
+	}
 
-	calcShortestPath(ctx, nodes, ways, relations, trainTracks, footWays, g, sourcePlatformID, destPlatformID)
+	var entries []export.ReportEntry
+	pairs := len(selections) * (len(selections) - 1)
+	for _, source := range selections {
+		for _, dest := range selections {
+			if source == dest {
+				continue
+			}
+			if cancelCtx.Err() != nil {
+				return nil, cancelCtx.Err()
+			}
+			entry := export.ReportEntry{From: serviceNames[source.Service], To: serviceNames[dest.Service]}
+			prefix := fmt.Sprintf("transfer %d of %d: ", len(entries)+1, pairs)
+			transferStatus := func(text string) {
+				status(prefix + text)
+			}
+			// without a window the user isn't asked for the platform edge
+			transfer, err := computeTransfer(cancelCtx, models.AppContext{}, transferStatus, nodes, ways, relations, trainTracks, mapWays, footWays, g, dataTimestamp, source, dest)
+			if errors.Is(err, context.Canceled) {
+				return nil, err
+			}
+			if err != nil {
+				log.Err(err).Msg("failed computing the transfer from " + entry.From + " to " + entry.To)
+			}
+			entry.Transfer = transfer
+			entry.Err = err
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
 }
 
-type GeoJSON struct {
-	Type       string      `json:"type"`
-	Geometry   Geometry    `json:"geometry"`
-	Properties interface{} `json:"properties"`
+// the name tag tells the directions of a line apart, the ref doesn't
+func reportServiceName(service *osm.Relation) string {
+	if name := service.Tags.Find("name"); name != "" {
+		return name
+	}
+	if ref := service.Tags.Find("ref"); ref != "" {
+		return ref
+	}
+	return "relation " + fmt.Sprint(service.ID)
 }
 
-type Geometry struct {
-	Type        string      `json:"type"`
-	Coordinates [][]float64 `json:"coordinates"` // For LineString
+// Replaces the content of a tab after its work was cancelled
+func showCancelled(ctx models.AppContext, tabIndex int) {
+	log.Info().Msg("cancelled work of tab " + fmt.Sprint(tabIndex))
+	ctx.Tabs.Items[tabIndex].Content = container.NewCenter(widget.NewLabel("Cancelled"))
+	ctx.Tabs.Refresh()
 }
 
+// Computes the route between the two selected platforms, writes it to the sink and displays it.
+// Only errors from cancelling cancelCtx are returned, everything else is shown to the user directly.
 func calcShortestPath(
+	cancelCtx context.Context,
 	ctx models.AppContext,
-	nodes map[osm.NodeID]*osm.Node,
+	cancelButton *ui.CancelButton,
+	nodes *nodestore.Store,
 	ways map[osm.WayID]*osm.Way,
 	relations map[osm.RelationID]*osm.Relation,
-	trainTracks []orb.Ring,
+	trainTracks *linebound.TrackIndex,
+	mapWays *linebound.WayIndex,
+	platformOutlines mapset.Set[osm.WayID],
 	footWays mapset.Set[osm.NodeID],
 	g *simple.WeightedDirectedGraph,
+	dataTimestamp time.Time,
+	sink *output.Sink,
+	station string,
 	sourcePlatformAndService models.PlatformAndServiceSelection,
 	destPlatformAndService models.PlatformAndServiceSelection,
-) {
+) error {
+	loadingContainer := ui.NewLoadingScreenWithTextWidget()
+	ctx.Tabs.Items[2].Content = container.NewVBox(loadingContainer, cancelButton)
+	ctx.Tabs.EnableIndex(2)
+	ctx.Tabs.SelectIndex(2)
+
+	query, err := sink.StartQuery(station)
+	if err != nil {
+		log.Err(err).Msg("can't write to the output directory")
+		dialog.ShowError(err, ctx.Window)
+		return nil
+	}
+	transfer, err := computeTransfer(cancelCtx, ctx, loadingContainer.SetText, nodes, ways, relations, trainTracks, mapWays, footWays, g, dataTimestamp, sourcePlatformAndService, destPlatformAndService)
+	if errors.Is(err, context.Canceled) {
+		query.Abort()
+		return err
+	}
+	if err != nil {
+		query.Abort()
+		log.Err(err).Msg("routing failed")
+		dialog.ShowError(err, ctx.Window)
+		return nil
+	}
+	if ctx.Saved != nil {
+		ctx.Saved.Add(saved.Transfer{
+			Station:  station,
+			Source:   formatSelection(transfer.Source.Selection),
+			Dest:     formatSelection(transfer.Dest.Selection),
+			Label:    export.TransferName(transfer),
+			LastUsed: time.Now(),
+		})
+	}
+
+	// the result is shown even if writing it failed, the artifacts that were written are still listed
+	artifacts, err := query.Finish(transfer)
+	if err != nil {
+		log.Err(err).Msg("failed writing the artifacts of the transfer")
+		dialog.ShowError(err, ctx.Window)
+	}
+	ui.DisplayResults(ctx, transfer, collectMapFeatures(transfer, nodes, ways, mapWays, platformOutlines), artifacts)
+	return nil
+}
+
+// Computes the route between the two selected platforms.
+// status is called with a description of the current step. Without a window in ctx the user isn't asked anything,
+// so it can be used without the UI.
+func computeTransfer(
+	cancelCtx context.Context,
+	ctx models.AppContext,
+	status func(string),
+	nodes *nodestore.Store,
+	ways map[osm.WayID]*osm.Way,
+	relations map[osm.RelationID]*osm.Relation,
+	trainTracks *linebound.TrackIndex,
+	mapWays *linebound.WayIndex,
+	footWays mapset.Set[osm.NodeID],
+	g *simple.WeightedDirectedGraph,
+	dataTimestamp time.Time,
+	sourcePlatformAndService models.PlatformAndServiceSelection,
+	destPlatformAndService models.PlatformAndServiceSelection,
+) (models.Transfer, error) {
 
 	relevantPlatformWays := mapset.NewSet[*osm.Way]()
 	relevantPlatformRelations := mapset.NewSet[*osm.Relation]()
 
-	infiniteLoadingBar := widget.NewProgressBarInfinite()
-	infiniteLoadingBar.Start()
-	loadingContainer := ui.NewLoadingScreenWithTextWidget()
-	loadingContainer.SetText("picking out relevant platform data")
-	// container.NewCenter(infiniteLoadingBar)
-	ctx.Tabs.Items[2].Content = loadingContainer
-	ctx.Tabs.EnableIndex(2)
-	ctx.Tabs.SelectIndex(2)
+	status("picking out relevant platform data")
+
+	// clipped data can miss the service relation of a saved or typed in selection
+	for _, selection := range []models.PlatformAndServiceSelection{sourcePlatformAndService, destPlatformAndService} {
+		if _, exists := relations[selection.Service]; !exists {
+			return models.Transfer{}, errors.New("service relation/" + fmt.Sprint(selection.Service) + " isn't part of the loaded data")
+		}
+	}
 
 	var sourceNodes []osm.NodeID
 	var targetNodes []osm.NodeID
 
-	platformSpines := make(map[osm.ElementID]models.PlatformSpine)
+	// spines are stored per selection since an island platform has a different spine for each side
+	selections := []models.PlatformAndServiceSelection{sourcePlatformAndService, destPlatformAndService}
+	platformSpines := make(map[models.PlatformAndServiceSelection]models.PlatformSpine)
+	// the edges used for platform relations, so the transfer can be run again without choosing them
+	usedEdges := make(map[models.PlatformAndServiceSelection]osm.WayID)
+	platformCentroids := make(map[models.PlatformAndServiceSelection]orb.Point)
 
 	sourcePlatformType, err := sourcePlatformAndService.Platform.Type()
 	if sourcePlatformType == "way" {
@@ -524,13 +1207,21 @@ func calcShortestPath(
 		if err != nil {
 			log.Err(err).Msg("cannot get WayID of " + fmt.Sprint(sourcePlatformAndService.Platform))
 		}
-		relevantPlatformWays.Add(ways[wayID])
+		way, exists := ways[wayID]
+		if !exists {
+			return models.Transfer{}, errors.New("source platform " + fmt.Sprint(sourcePlatformAndService.Platform) + " isn't part of the loaded data")
+		}
+		relevantPlatformWays.Add(way)
 	} else if sourcePlatformType == "relation" {
 		relationID, err := sourcePlatformAndService.Platform.RelationID()
 		if err != nil {
 			log.Err(err).Msg("cannot get RelationID of " + fmt.Sprint(sourcePlatformAndService.Platform))
 		}
-		relevantPlatformRelations.Add(relations[relationID])
+		relation, exists := relations[relationID]
+		if !exists {
+			return models.Transfer{}, errors.New("source platform " + fmt.Sprint(sourcePlatformAndService.Platform) + " isn't part of the loaded data")
+		}
+		relevantPlatformRelations.Add(relation)
 	} else {
 		log.Err(nil).Msg("source platform not of type way or relation")
 	}
@@ -541,41 +1232,73 @@ func calcShortestPath(
 		if err != nil {
 			log.Err(err).Msg("cannot get WayID of " + fmt.Sprint(destPlatformAndService.Platform))
 		}
-		relevantPlatformWays.Add(ways[wayID])
+		way, exists := ways[wayID]
+		if !exists {
+			return models.Transfer{}, errors.New("dest platform " + fmt.Sprint(destPlatformAndService.Platform) + " isn't part of the loaded data")
+		}
+		relevantPlatformWays.Add(way)
 	} else if destPlatformType == "relation" {
 		relationID, err := destPlatformAndService.Platform.RelationID()
 		if err != nil {
 			log.Err(err).Msg("cannot get RelationID of " + fmt.Sprint(destPlatformAndService.Platform))
 		}
-		relevantPlatformRelations.Add(relations[relationID])
+		relation, exists := relations[relationID]
+		if !exists {
+			return models.Transfer{}, errors.New("dest platform " + fmt.Sprint(destPlatformAndService.Platform) + " isn't part of the loaded data")
+		}
+		relevantPlatformRelations.Add(relation)
 	} else {
 		log.Err(nil).Msg("dest platform not of type way or relation")
 	}
 
-	loadingContainer.SetText("checking closeness of platform to rails")
+	status("checking closeness of platform to rails")
 
 	var allClosePoints []osm.Node
+	// everything that was guessed or not found, shown with the result
+	var warnings []string
 
 	closenessStart := time.Now()
 	for platform := range relevantPlatformWays.Iterator().C {
 
 		var platformNodes []osm.Node
 		for _, wayNode := range platform.Nodes {
-			platformNodes = append(platformNodes, *nodes[wayNode.ID])
+			node := nodes.Node(wayNode.ID)
+			if node == nil {
+				log.Warn().Msg("node " + fmt.Sprint(wayNode.ID) + " of platform " + fmt.Sprint(platform.ElementID()) + " is outside the loaded data")
+				continue
+			}
+			platformNodes = append(platformNodes, *node)
 		}
 
-		currentSpine := platformSpines[platform.ElementID()]
-		if platform.Tags.Find("area") == "yes" {
-			linebound.SetPlatformSpine(ctx, platformNodes, platformSpines, trainTracks, nodes, platform.ElementID(), &allClosePoints)
-		} else {
-			startNode := linebound.NodeToPoint(*nodes[platform.Nodes[0].ID])
-			endNode := linebound.NodeToPoint(*nodes[platform.Nodes[len(platform.Nodes)-1].ID])
-			currentSpine.Start = startNode
-			currentSpine.End = endNode
-			log.Debug().Msg("Platform " + fmt.Sprint(platform.ElementID()) + " is not area and has spine " + fmt.Sprint(currentSpine))
+		for _, selection := range selections {
+			if platform.ElementID() != selection.Platform {
+				continue
+			}
+			platformCentroids[selection] = linebound.NodesCentroid(platformNodes)
+			if platform.Tags.Find("area") == "yes" {
+				spine, err := findServiceSpine(cancelCtx, ctx, platformNodes, selection, nodes, ways, relations, trainTracks, &allClosePoints, &warnings)
+				if errors.Is(err, context.Canceled) {
+					return models.Transfer{}, err
+				}
+				if err != nil {
+					log.Warn().Err(err).Msg("failed finding spine of platform " + fmt.Sprint(platform.ElementID()))
+				} else {
+					platformSpines[selection] = spine
+				}
+			} else {
+				if len(platformNodes) < 2 {
+					log.Warn().Msg("platform " + fmt.Sprint(platform.ElementID()) + " has less than two nodes in the loaded data")
+					continue
+				}
+				var currentSpine models.PlatformSpine
+				currentSpine.Start = linebound.NodeToPoint(platformNodes[0])
+				currentSpine.End = linebound.NodeToPoint(platformNodes[len(platformNodes)-1])
+				platformSpines[selection] = currentSpine
+				log.Debug().Msg("Platform " + fmt.Sprint(platform.ElementID()) + " is not area and has spine " + fmt.Sprint(currentSpine))
+			}
 		}
 		for _, node := range platform.Nodes {
-			if (nodes[node.ID].Tags.Find("level") != "") || footWays.Contains(node.ID) {
+			if (nodes.Tags(node.ID).Find("level") != "") || footWays.Contains(node.ID) {
 				if platform.ElementID() == sourcePlatformAndService.Platform {
 					sourceNodes = append(sourceNodes, node.ID)
 				}
@@ -585,51 +1308,36 @@ func calcShortestPath(
 			}
 		}
 	}
-	loadingContainer.SetText("getting platform numbers")
+	status("getting platform numbers")
 	for platform := range relevantPlatformRelations.Iterator().C {
 		// point nodes are all points on the platform. also includes inners, since that is an okay destination
 		var platformPointNodes []osm.Node
 		// spine search nodes should only be outers, since inners can confuse the algorithm since it should only be used the the outside of the platform
 		var platformSpineSearchNodes []osm.Node
 
-		var platformNumber string
-		// sourcePlatformAndService models.PlatformAndServiceSelection,
-		if platform.ElementID() == sourcePlatformAndService.Platform {
-			platformNumber, err = getPlatformNumberOfService(sourcePlatformAndService.Platform, nodes, ways, relations, *relations[sourcePlatformAndService.Service])
-			if err != nil {
-				log.Warn().Msg("couldn't get the platform number of service relation/" + fmt.Sprint(sourcePlatformAndService.Service) + " at platform " + fmt.Sprint(sourcePlatformAndService.Platform))
-			}
-		}
-		if platform.ElementID() == destPlatformAndService.Platform {
-			platformNumber, err = getPlatformNumberOfService(destPlatformAndService.Platform, nodes, ways, relations, *relations[destPlatformAndService.Service])
-			if err != nil {
-				log.Warn().Msg("couldn't get the platform number of service relation/" + fmt.Sprint(destPlatformAndService.Service) + " at platform " + fmt.Sprint(destPlatformAndService.Platform))
-			}
-		}
-
 		var platformEdges []*osm.Way
-		foundPlatformEdge := false
 		for _, member := range platform.Members {
 			if member.Type == osm.TypeWay {
 				wayID, err := member.ElementID().WayID()
 				if err != nil {
 					log.Err(err).Msg("determining WayID of platform member" + fmt.Sprint(member.ElementID()) + " failed since it is not of type way")
 				}
-				way := ways[wayID]
-				// is platform edge and didn't find a matching platform edge already
-				if way.Tags.Find("railway") == "platform_edge" && foundPlatformEdge == false {
+				way, exists := ways[wayID]
+				if !exists {
+					log.Warn().Msg("member way " + fmt.Sprint(wayID) + " of platform " + fmt.Sprint(platform.ID) + " is outside the loaded data")
+					continue
+				}
+				if way.Tags.Find("railway") == "platform_edge" {
 					log.Debug().Msg("way " + fmt.Sprint(wayID) + " in relation " + fmt.Sprint(platform.ID) + " is platform_edge")
-					// checks if any of the platform numbers are mentioned in a stop_position contained in the service relation with the same name
-					if way.Tags.Find("ref") == platformNumber {
-						foundPlatformEdge = true
-						platformEdges = []*osm.Way{way}
-					} else {
-						platformEdges = append(platformEdges, way)
-					}
+					platformEdges = append(platformEdges, way)
 				}
 				for _, wayNode := range way.Nodes {
 					// since way nodes don't have tags i need to find the original node in the map
-					node := nodes[wayNode.ID]
+					node := nodes.Node(wayNode.ID)
+					if node == nil {
+						log.Warn().Msg("node " + fmt.Sprint(wayNode.ID) + " of platform " + fmt.Sprint(platform.ID) + " is outside the loaded data")
+						continue
+					}
 					platformPointNodes = append(platformPointNodes, *node)
 					if member.Role != "inner" {
 						platformSpineSearchNodes = append(platformSpineSearchNodes, *node)
@@ -638,26 +1346,38 @@ func calcShortestPath(
 			}
 		}
 
-		if platformEdges == nil {
-			linebound.SetPlatformSpine(ctx, platformSpineSearchNodes, platformSpines, trainTracks, nodes, platform.ElementID(), &allClosePoints)
-		} else {
-			var platformEdgeToUse osm.Way
-			if len(platformEdges) == 1 {
-				platformEdgeToUse = *platformEdges[0]
-				log.Info().Msg("selected platform number " + fmt.Sprint(platformEdgeToUse.Tags.Find("ref")) + " for platform " + fmt.Sprint(platform.ElementID()))
+		for _, selection := range selections {
+			if platform.ElementID() != selection.Platform {
+				continue
+			}
+			platformCentroids[selection] = linebound.NodesCentroid(platformSpineSearchNodes)
+			if platformEdges == nil {
+				spine, err := findServiceSpine(cancelCtx, ctx, platformSpineSearchNodes, selection, nodes, ways, relations, trainTracks, &allClosePoints, &warnings)
+				if errors.Is(err, context.Canceled) {
+					return models.Transfer{}, err
+				}
+				if err != nil {
+					log.Warn().Err(err).Msg("failed finding spine of platform " + fmt.Sprint(platform.ElementID()))
+				} else {
+					platformSpines[selection] = spine
+				}
 			} else {
-				platformEdgeToUseChan := make(chan osm.Way)
-				ui.ShowPlatformEdgeSelector(ctx.Window, platformEdges, platformEdgeToUseChan)
-				platformEdgeToUse = <-platformEdgeToUseChan
+				platformEdgeToUse, err := selectPlatformEdge(cancelCtx, ctx, platformEdges, selection, nodes, ways, relations, &warnings)
+				if err != nil {
+					return models.Transfer{}, err
+				}
+				var edgeSpine models.PlatformSpine
+				edgeSpine.Start, edgeSpine.End, err = wayEndPoints(&platformEdgeToUse, nodes)
+				if err != nil {
+					return models.Transfer{}, err
+				}
+				log.Debug().Msg("edge spine: " + fmt.Sprint(edgeSpine))
+				platformSpines[selection] = edgeSpine
+				usedEdges[selection] = platformEdgeToUse.ID
 			}
-			var edgeSpine models.PlatformSpine
-			edgeSpine.Start = linebound.NodeToPoint(*nodes[platformEdgeToUse.Nodes[0].ID])
-			edgeSpine.End = linebound.NodeToPoint(*nodes[platformEdgeToUse.Nodes[len(platformEdgeToUse.Nodes)-1].ID])
-			log.Debug().Msg("edge spine: " + fmt.Sprint(edgeSpine))
-			platformSpines[platform.ElementID()] = edgeSpine
 		}
 		for _, node := range platformPointNodes {
-			if (nodes[node.ID].Tags.Find("level") != "") || footWays.Contains(node.ID) {
+			if (nodes.Tags(node.ID).Find("level") != "") || footWays.Contains(node.ID) {
 				if platform.ElementID() == sourcePlatformAndService.Platform {
 					sourceNodes = append(sourceNodes, node.ID)
 				}
@@ -668,35 +1388,6 @@ func calcShortestPath(
 		}
 	}
 
-	geoJsonCloseNodes := GeoJSON{
-		Type: "Feature",
-		Geometry: Geometry{
-			Type: "LineString",
-		},
-	}
-
-	// debug output
-	for _, node := range allClosePoints {
-		// Assuming the node ID corresponds to the OSM node ID
-		if coord, exists := nodes[osm.NodeID(node.ID)]; exists {
-			geoJsonCloseNodes.Geometry.Coordinates = append(geoJsonCloseNodes.Geometry.Coordinates, []float64{coord.Lon, coord.Lat})
-		}
-	}
-
-	file, err := os.Create("close-nodes.geojson")
-	if err != nil {
-		log.Err(err).Msg("Error creating file:")
-		dialog.ShowError(err, ctx.Window)
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(geoJsonCloseNodes); err != nil {
-		log.Err(err).Msg("Error encoding GeoJSON:")
-		dialog.ShowError(err, ctx.Window)
-	}
-
 	elapsed := time.Since(closenessStart)
 	log.Debug().Msg("Closeness checking took " + fmt.Sprint(elapsed) + "s")
 	routingTime := time.Now()
@@ -704,16 +1395,25 @@ func calcShortestPath(
 	log.Info().Msg("source nodes: " + fmt.Sprint(sourceNodes))
 	log.Info().Msg("target nodes: " + fmt.Sprint(targetNodes))
 
-	loadingContainer.SetText("calculating shortest path")
+	status("calculating shortest path")
 
-	shortestPath, shortestWeight := shortestPathBetweenArrayOfNodes(sourceNodes, targetNodes, g, loadingContainer)
-	fmt.Printf("Shortest path: %v (weight: %v)\n", shortestPath, shortestWeight)
+	shortestPath, shortestWeight, err := shortestPathBetweenArrayOfNodes(cancelCtx, sourceNodes, targetNodes, g, status)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	log.Debug().Msg("shortest path: " + fmt.Sprint(shortestPath) + " with weight " + fmt.Sprint(shortestWeight))
+	if len(shortestPath) == 0 {
+		warnings = append(warnings, "no walking path was found between the platforms")
+	}
 
 	var sourceExit osm.Node
 	sourceExitFound := false
 	var destExit osm.Node
 	for _, graphNode := range shortestPath {
-		node := nodes[osm.NodeID(graphNode.ID())]
+		node := nodes.Node(osm.NodeID(graphNode.ID()))
+		if node == nil {
+			continue
+		}
 		if node.Tags.Find("level") != "" {
 			if sourceExitFound == false {
 				sourceExitFound = true
@@ -725,34 +1425,47 @@ func calcShortestPath(
 	elapsed = time.Since(routingTime)
 	log.Printf("Routing took %s", elapsed)
 	outputTime := time.Now()
-	loadingContainer.SetText("formatting output")
+	status("formatting output")
 
-	sourceSpine := platformSpines[sourcePlatformAndService.Platform]
-	destSpine := platformSpines[destPlatformAndService.Platform]
+	sourceSpine := platformSpines[sourcePlatformAndService]
+	destSpine := platformSpines[destPlatformAndService]
 
 	if sourceSpine == (models.PlatformSpine{}) {
 		log.Debug().Msg(fmt.Sprint(platformSpines))
-		error := errors.New("nil value in platform spine")
-		log.Err(error).Msg("nil value in source platform spine")
-		dialog.ShowError(error, ctx.Window)
+		return models.Transfer{}, errors.New("found no spine for the source platform " + fmt.Sprint(sourcePlatformAndService.Platform))
 	}
 	if destSpine == (models.PlatformSpine{}) {
 		log.Debug().Msg(fmt.Sprint(platformSpines))
-		error := errors.New("nil value in platform spine")
-		log.Err(error).Msg("nil value in dest platform spine")
-		dialog.ShowError(error, ctx.Window)
+		return models.Transfer{}, errors.New("found no spine for the dest platform " + fmt.Sprint(destPlatformAndService.Platform))
 	}
 	log.Debug().Msg("source spine: " + fmt.Sprint(sourceSpine))
 	log.Debug().Msg("dest spine: " + fmt.Sprint(destSpine))
 
 	log.Info().Msg("correcting source spine orientations")
-	sourceSpine = correctSpineOrientation(sourceSpine, sourcePlatformAndService, nodes, relations)
+	sourceSpine = correctSpineOrientation(sourceSpine, sourcePlatformAndService, nodes, ways, relations, &warnings)
 	log.Info().Msg("correcting dest spine orientations")
-	destSpine = correctSpineOrientation(destSpine, destPlatformAndService, nodes, relations)
+	destSpine = correctSpineOrientation(destSpine, destPlatformAndService, nodes, ways, relations, &warnings)
 
 	log.Debug().Msg("source spine modified: " + fmt.Sprint(sourceSpine))
 	log.Debug().Msg("dest spine modified: " + fmt.Sprint(destSpine))
 
+	sourceSide := determinePlatformSide(sourceSpine, sourcePlatformAndService, platformCentroids[sourcePlatformAndService], nodes, ways, relations)
+	destSide := determinePlatformSide(destSpine, destPlatformAndService, platformCentroids[destPlatformAndService], nodes, ways, relations)
+	log.Info().Msg("source platform is on the " + sourceSide.String() + " of the train, dest platform on the " + destSide.String())
+	if sourceSide == models.PlatformSideUnknown {
+		warnings = append(warnings, "the side of the source platform is unknown")
+	}
+	if destSide == models.PlatformSideUnknown {
+		warnings = append(warnings, "the side of the dest platform is unknown")
+	}
+	// without an exit the door is placed by projecting the zero node onto the spine
+	if sourceExit.ID == 0 {
+		warnings = append(warnings, "exit node of the source platform not found, the door position is a guess")
+	}
+	if destExit.ID == 0 {
+		warnings = append(warnings, "exit node of the dest platform not found, the door position is a guess")
+	}
+
 	sourcePoint0 := linebound.OrbPointToGeoPoint(sourceSpine.Start)
 	sourcePoint1 := linebound.OrbPointToGeoPoint(sourceSpine.End)
 
@@ -786,44 +1499,426 @@ func calcShortestPath(
 	log.Info().Msg("starting exit: " + fmt.Sprint(sourceExit.ID))
 	log.Info().Msg("ending exit: " + fmt.Sprint(destExit.ID))
 
-	geo := GeoJSON{
-		Type: "Feature",
-		Geometry: Geometry{
-			Type: "LineString",
-		},
+	transfer := models.Transfer{
+		Source:        buildTransferEnd(sourcePlatformAndService, sourceSpine, sourceSide, sourceExit, sourceOptimalDoor, fromPlatformStart, sourcePlatformLength, nodes, ways, relations),
+		Dest:          buildTransferEnd(destPlatformAndService, destSpine, destSide, destExit, destOptimalDoor, toPlatformStart, destPlatformLength, nodes, ways, relations),
+		PathWeight:    shortestWeight,
+		DataTimestamp: dataTimestamp,
+	}
+	transfer.Source.Selection.Edge = usedEdges[sourcePlatformAndService]
+	transfer.Dest.Selection.Edge = usedEdges[destPlatformAndService]
+	for _, graphNode := range shortestPath {
+		nodeID := osm.NodeID(graphNode.ID())
+		point, exists := nodes.Point(nodeID)
+		if !exists {
+			continue
+		}
+		if len(transfer.Path) > 0 {
+			transfer.PathDistance += geo.Distance(transfer.Path[len(transfer.Path)-1].Point, point)
+		}
+		transfer.Path = append(transfer.Path, models.PathNode{ID: nodeID, Point: point, Level: nodes.Tags(nodeID).Find("level")})
+	}
+	for _, node := range allClosePoints {
+		if point, exists := nodes.Point(node.ID); exists {
+			transfer.ClosePoints = append(transfer.ClosePoints, point)
+		}
+	}
+	summarizePath(&transfer, nodes, ways, mapWays)
+	transfer.Warnings = warnings
+
+	elapsed = time.Since(outputTime)
+	log.Printf("Routing and output took %s", elapsed)
+	return transfer, nil
+}
+
+// walking speeds in meters per second for estimating the walking time, distances on steps and escalators are measured horizontally
+const (
+	walkingSpeed   = ingest.WalkingSpeed
+	stepsSpeed     = 0.5
+	escalatorSpeed = 0.75
+	// the same time the walking graph uses for weighting elevators
+	elevatorSeconds = ingest.ElevatorSeconds
+)
+
+// Fills in the walking time, level changes and vertical means of the transfer from the ways along its path
+func summarizePath(transfer *models.Transfer, nodes *nodestore.Store, ways map[osm.WayID]*osm.Way, mapWays *linebound.WayIndex) {
+	// the graph doesn't know which way an edge belongs to, so the ways are looked up by their segments
+	type segment struct {
+		from osm.NodeID
+		to   osm.NodeID
+	}
+	segmentWays := make(map[segment]*osm.Way)
+	var pathBound orb.Bound
+	for i, node := range transfer.Path {
+		if i == 0 {
+			pathBound = node.Point.Bound()
+		} else {
+			pathBound = pathBound.Extend(node.Point)
+			segmentWays[segment{transfer.Path[i-1].ID, node.ID}] = nil
+		}
+	}
+	// only the ways around the path can contain its segments
+	var pathWays []osm.WayID
+	if len(transfer.Path) > 1 {
+		pathWays = mapWays.Intersecting(pathBound)
+	}
+	for _, wayID := range pathWays {
+		way, exists := ways[wayID]
+		if !exists || !ingest.IsWalkableWay(way) {
+			continue
+		}
+		for i := 0; i+1 < len(way.Nodes); i++ {
+			for _, waySegment := range []segment{{way.Nodes[i].ID, way.Nodes[i+1].ID}, {way.Nodes[i+1].ID, way.Nodes[i].ID}} {
+				if _, used := segmentWays[waySegment]; used {
+					segmentWays[waySegment] = way
+				}
+			}
+		}
+	}
+
+	addVerticalMeans := func(kind models.VerticalMeansKind, feature osm.FeatureID) {
+		// consecutive segments of the same way are used once
+		if count := len(transfer.VerticalMeans); count > 0 && transfer.VerticalMeans[count-1].Feature == feature {
+			return
+		}
+		transfer.VerticalMeans = append(transfer.VerticalMeans, models.VerticalMeans{Kind: kind, Feature: feature})
+	}
+
+	var seconds float64
+	var lastLevel string
+	for i, node := range transfer.Path {
+		if node.Level != "" {
+			if lastLevel != "" && node.Level != lastLevel {
+				transfer.LevelChanges++
+			}
+			lastLevel = node.Level
+		}
+		if nodes.Tags(node.ID).Find("highway") == "elevator" {
+			addVerticalMeans(models.VerticalMeansElevator, node.ID.FeatureID())
+			seconds += elevatorSeconds
+		}
+		if i == 0 {
+			continue
+		}
+		previous := transfer.Path[i-1]
+		speed := walkingSpeed
+		if way := segmentWays[segment{previous.ID, node.ID}]; way != nil && way.Tags.Find("highway") == "steps" {
+			if way.Tags.Find("conveying") != "" {
+				speed = escalatorSpeed
+				addVerticalMeans(models.VerticalMeansEscalator, way.FeatureID())
+			} else {
+				speed = stepsSpeed
+				addVerticalMeans(models.VerticalMeansSteps, way.FeatureID())
+			}
+		}
+		seconds += geo.Distance(previous.Point, node.Point) / speed
+	}
+	transfer.WalkingTime = time.Duration(seconds * float64(time.Second)).Round(time.Second)
+}
+
+// Collects everything about one platform of the transfer for displaying and exporting it
+func buildTransferEnd(
+	selection models.PlatformAndServiceSelection,
+	spine models.PlatformSpine,
+	side models.PlatformSide,
+	exit osm.Node,
+	door orb.Point,
+	doorDistance float64,
+	spineLength float64,
+	nodes *nodestore.Store,
+	ways map[osm.WayID]*osm.Way,
+	relations map[osm.RelationID]*osm.Relation,
+) models.TransferEnd {
+	end := models.TransferEnd{
+		Selection:    selection,
+		Spine:        spine,
+		Side:         side,
+		Door:         door,
+		DoorDistance: doorDistance,
+		SpineLength:  spineLength,
+	}
+	if exit.ID != 0 {
+		end.Exit = models.PathNode{ID: exit.ID, Point: linebound.NodeToPoint(exit), Level: exit.Tags.Find("level")}
+	}
+	switch platformType, _ := selection.Platform.Type(); platformType {
+	case osm.TypeWay:
+		if platform, exists := ways[osm.WayID(selection.Platform.Ref())]; exists {
+			end.PlatformName = platform.Tags.Find("name")
+			end.PlatformRef = platform.Tags.Find("ref")
+		}
+	case osm.TypeRelation:
+		if platform, exists := relations[osm.RelationID(selection.Platform.Ref())]; exists {
+			end.PlatformName = platform.Tags.Find("name")
+			end.PlatformRef = platform.Tags.Find("ref")
+		}
+	}
+
+	service, exists := relations[selection.Service]
+	if !exists {
+		return end
+	}
+	end.ServiceName = service.Tags.Find("name")
+	end.ServiceRef = service.Tags.Find("ref")
+	end.ServiceColour = service.Tags.Find("colour")
+
+	trackWays := linebound.RouteTrackWays(*service, ways)
+	_, frontTrackPoint, frontErr := linebound.TravelBearingNearPoint(spine.Start, trackWays, nodes, maxSpineTrackDistance)
+	_, backTrackPoint, backErr := linebound.TravelBearingNearPoint(spine.End, trackWays, nodes, maxSpineTrackDistance)
+	if frontErr == nil && backErr == nil {
+		end.StoppingRange = orb.LineString{frontTrackPoint, backTrackPoint}
+	} else {
+		log.Debug().Msg("no stopping range for service " + fmt.Sprint(selection.Service) + " at platform " + fmt.Sprint(selection.Platform))
+	}
+	// only the bounds next to the platform, a route has thousands of them
+	aroundSpine := geo.BoundPad(orb.LineString{spine.Start, spine.End}.Bound(), maxSpineTrackDistance)
+	for _, bound := range linebound.TrackBounds(trackWays, nodes, ingest.TrackBoundPadding) {
+		if bound.Bound().Intersects(aroundSpine) {
+			end.TrackBuffers = append(end.TrackBuffers, bound)
+		}
+	}
+	return end
+}
+
+// the distance in meters around the transfer that is drawn on the station map
+const stationMapPadding = 100
+
+// Collects the platforms, tracks and walkable ways around the transfer for drawing the station map
+func collectMapFeatures(transfer models.Transfer, nodes *nodestore.Store, ways map[osm.WayID]*osm.Way, mapWays *linebound.WayIndex, platformOutlines mapset.Set[osm.WayID]) []models.MapFeature {
+	transferBound := orb.MultiPoint{transfer.Source.Spine.Start, transfer.Source.Spine.End, transfer.Dest.Spine.Start, transfer.Dest.Spine.End}.Bound()
+	for _, node := range transfer.Path {
+		transferBound = transferBound.Extend(node.Point)
 	}
+	bound := geo.BoundPad(transferBound, stationMapPadding)
+
+	var features []models.MapFeature
+	for _, wayID := range mapWays.Intersecting(bound) {
+		way, exists := ways[wayID]
+		if !exists {
+			continue
+		}
+		var kind models.MapFeatureKind
+		switch {
+		// the outlines of multipolygon platforms usually have no tags themselves
+		case ingest.IsPlatform(way.Tags) || platformOutlines.Contains(way.ID):
+			kind = models.MapFeaturePlatform
+		case ingest.IsTrackWay(way):
+			kind = models.MapFeatureTrack
+		case ingest.IsWalkableWay(way) && way.Tags.Find("conveying") != "":
+			kind = models.MapFeatureEscalator
+		case way.Tags.Find("highway") == "steps":
+			kind = models.MapFeatureSteps
+		case ingest.IsWalkableWay(way):
+			kind = models.MapFeatureFootway
+		default:
+			continue
+		}
 
-	ui.DisplayResults(ctx, alongSourcePlatform, alongDestPlatform, fromPlatformStart, toPlatformStart)
+		line := make(orb.LineString, 0, len(way.Nodes))
+		for _, wayNode := range way.Nodes {
+			if point, exists := nodes.Point(wayNode.ID); exists {
+				line = append(line, point)
+			}
+		}
+		// a long way can cross the station without any of its nodes inside the bound
+		if len(line) > 1 && lineIntersectsBound(line, bound) {
+			features = append(features, models.MapFeature{Kind: kind, Line: line})
+		}
+	}
+	return features
+}
 
-	for _, node := range shortestPath {
-		// Assuming the node ID corresponds to the OSM node ID
-		if coord, exists := nodes[osm.NodeID(node.ID())]; exists {
-			geo.Geometry.Coordinates = append(geo.Geometry.Coordinates, []float64{coord.Lon, coord.Lat})
+// reports if any segment of the line has a bounding box overlapping the bound
+func lineIntersectsBound(line orb.LineString, bound orb.Bound) bool {
+	for i := 0; i+1 < len(line); i++ {
+		if bound.Intersects(orb.MultiPoint{line[i], line[i+1]}.Bound()) {
+			return true
 		}
 	}
+	return false
+}
 
-	file, err = os.Create("path.geojson")
+// Finds the spine of an area platform on the side facing the tracks the service uses.
+// If no part of the platform is close to the service's tracks, all tracks are used instead.
+func findServiceSpine(
+	cancelCtx context.Context,
+	ctx models.AppContext,
+	platformNodes []osm.Node,
+	selection models.PlatformAndServiceSelection,
+	nodes *nodestore.Store,
+	ways map[osm.WayID]*osm.Way,
+	relations map[osm.RelationID]*osm.Relation,
+	trainTracks *linebound.TrackIndex,
+	allClosePoints *[]osm.Node,
+	warnings *[]string,
+) (models.PlatformSpine, error) {
+	if err := cancelCtx.Err(); err != nil {
+		return models.PlatformSpine{}, err
+	}
+	serviceTracks := linebound.NewTrackIndex(linebound.TrackBounds(linebound.RouteTrackWays(*relations[selection.Service], ways), nodes, ingest.TrackBoundPadding))
+	spine, err := linebound.FindPlatformSpine(ctx, platformNodes, serviceTracks, nodes, selection.Platform, allClosePoints)
 	if err != nil {
-		log.Err(err).Msg("Error creating file:")
-		dialog.ShowError(err, ctx.Window)
+		log.Warn().Err(err).Msg("no spine along the tracks of service " + fmt.Sprint(selection.Service) + ", falling back to all tracks")
+		if err := cancelCtx.Err(); err != nil {
+			return models.PlatformSpine{}, err
+		}
+		*warnings = append(*warnings, "the spine of platform "+selection.Platform.FeatureID().String()+" was guessed from all tracks since none of the tracks of service "+fmt.Sprint(selection.Service)+" are close to it")
+		return linebound.FindPlatformSpine(ctx, platformNodes, trainTracks, nodes, selection.Platform, allClosePoints)
 	}
-	defer file.Close()
+	return spine, nil
+}
 
-	encoder = json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(geo); err != nil {
-		log.Err(err).Msg("Error encoding GeoJSON:")
-		dialog.ShowError(err, ctx.Window)
+// Picks the platform edge the service stops at.
+// The edge is matched by the platform number of the service, then by its distance to the tracks of the service and only then the user is asked.
+// An error is only returned if cancelCtx is cancelled while waiting for the user.
+func selectPlatformEdge(
+	cancelCtx context.Context,
+	ctx models.AppContext,
+	platformEdges []*osm.Way,
+	selection models.PlatformAndServiceSelection,
+	nodes *nodestore.Store,
+	ways map[osm.WayID]*osm.Way,
+	relations map[osm.RelationID]*osm.Relation,
+	warnings *[]string,
+) (osm.Way, error) {
+	// the edge chosen the last time the transfer was run
+	if selection.Edge != 0 {
+		for _, edge := range platformEdges {
+			if edge.ID == selection.Edge {
+				log.Info().Msg("using the given platform edge " + fmt.Sprint(edge.ID) + " for platform " + fmt.Sprint(selection.Platform))
+				return *edge, nil
+			}
+		}
+		log.Warn().Msg("platform edge " + fmt.Sprint(selection.Edge) + " isn't an edge of platform " + fmt.Sprint(selection.Platform) + " anymore")
 	}
-	elapsed = time.Since(outputTime)
-	log.Printf("Routing and output took %s", elapsed)
+
+	if len(platformEdges) == 1 {
+		log.Info().Msg("selected platform number " + fmt.Sprint(platformEdges[0].Tags.Find("ref")) + " for platform " + fmt.Sprint(selection.Platform))
+		return *platformEdges[0], nil
+	}
+
+	platformNumber, err := getPlatformNumberOfService(selection.Platform, nodes, ways, relations, *relations[selection.Service])
+	if err != nil {
+		log.Warn().Msg("couldn't get the platform number of service relation/" + fmt.Sprint(selection.Service) + " at platform " + fmt.Sprint(selection.Platform))
+	} else {
+		// checks if any of the platform numbers are mentioned in a stop_position contained in the service relation with the same name
+		for _, edge := range platformEdges {
+			if edge.Tags.Find("ref") == platformNumber {
+				log.Info().Msg("selected platform number " + platformNumber + " for platform " + fmt.Sprint(selection.Platform))
+				return *edge, nil
+			}
+		}
+	}
+
+	trackWays := linebound.RouteTrackWays(*relations[selection.Service], ways)
+	var closestEdge *osm.Way
+	closestDistance := math.Inf(1)
+	for _, edge := range platformEdges {
+		edgeStart, edgeEnd, err := wayEndPoints(edge, nodes)
+		if err != nil {
+			log.Warn().Err(err).Msg("skipping platform edge " + fmt.Sprint(edge.ID))
+			continue
+		}
+		edgeMiddle := geo.Midpoint(edgeStart, edgeEnd)
+		distance := linebound.DistanceToWays(edgeMiddle, trackWays, nodes)
+		if distance < closestDistance {
+			closestDistance = distance
+			closestEdge = edge
+		}
+	}
+	if closestEdge != nil && closestDistance <= maxEdgeTrackDistance {
+		log.Info().Msg("selected platform edge " + fmt.Sprint(closestEdge.ID) + " which is " + fmt.Sprint(closestDistance) + "m from the tracks of service " + fmt.Sprint(selection.Service))
+		return *closestEdge, nil
+	}
+
+	// without a window nobody can be asked, so the closest edge is used even if it is far from the tracks
+	if ctx.Window == nil {
+		if closestEdge == nil {
+			closestEdge = platformEdges[0]
+		}
+		log.Warn().Msg("guessing platform edge " + fmt.Sprint(closestEdge.ID) + " for platform " + fmt.Sprint(selection.Platform))
+		*warnings = append(*warnings, "platform edge way/"+fmt.Sprint(closestEdge.ID)+" of platform "+selection.Platform.FeatureID().String()+" was guessed")
+		return *closestEdge, nil
+	}
+
+	// buffered, so the dialog doesn't block if the selection was cancelled in the meantime
+	platformEdgeToUseChan := make(chan osm.Way, 1)
+	ui.ShowPlatformEdgeSelector(ctx.Window, platformEdges, platformEdgeToUseChan)
+	select {
+	case edge := <-platformEdgeToUseChan:
+		return edge, nil
+	case <-cancelCtx.Done():
+		return osm.Way{}, cancelCtx.Err()
+	}
+}
+
+// Determines on which side of the train, in the direction of travel, the platform lies.
+// This is the side of the track the platform centroid is on, seen in the direction the route traverses the track.
+func determinePlatformSide(
+	spine models.PlatformSpine,
+	selection models.PlatformAndServiceSelection,
+	platformCentroid orb.Point,
+	nodes *nodestore.Store,
+	ways map[osm.WayID]*osm.Way,
+	relations map[osm.RelationID]*osm.Relation,
+) models.PlatformSide {
+	trackWays := linebound.RouteTrackWays(*relations[selection.Service], ways)
+	travelBearing, trackPoint, err := linebound.TravelBearingNearPoint(geo.Midpoint(spine.Start, spine.End), trackWays, nodes, maxSpineTrackDistance)
+	if err != nil {
+		log.Warn().Err(err).Msg("can't determine platform side of service " + fmt.Sprint(selection.Service))
+		return models.PlatformSideUnknown
+	}
+	// the centroid of a platform mapped as a line can lie right on top of the track
+	if geo.Distance(trackPoint, platformCentroid) < 1 {
+		log.Warn().Msg("platform " + fmt.Sprint(selection.Platform) + " is too close to the track of service " + fmt.Sprint(selection.Service) + " to determine its side")
+		return models.PlatformSideUnknown
+	}
+	if linebound.BearingDifference(travelBearing, geo.Bearing(trackPoint, platformCentroid)) > 0 {
+		return models.PlatformSideRight
+	}
+	return models.PlatformSideLeft
+}
+
+// the maximum distance in meters between the middle of a platform spine and the track the service uses at the platform
+const maxSpineTrackDistance = 50
+
+// Orients the spine so that it starts at the end of the platform the train is heading towards (the front of the train).
+// The direction of travel is read from the order in which the service's route traverses the track next to the platform.
+func correctSpineOrientation(inputSpine models.PlatformSpine, selection models.PlatformAndServiceSelection, nodes *nodestore.Store, ways map[osm.WayID]*osm.Way, relations map[osm.RelationID]*osm.Relation, warnings *[]string) models.PlatformSpine {
+	serviceObject := relations[selection.Service]
+
+	trackWays := linebound.RouteTrackWays(*serviceObject, ways)
+	spineMiddle := geo.Midpoint(inputSpine.Start, inputSpine.End)
+	travelBearing, _, err := linebound.TravelBearingNearPoint(spineMiddle, trackWays, nodes, maxSpineTrackDistance)
+	if err != nil {
+		log.Warn().Err(err).Msg("couldn't determine direction of travel of service " + fmt.Sprint(selection.Service) + " from its route ways, falling back to the next stop")
+		*warnings = append(*warnings, "the direction of travel of service "+fmt.Sprint(selection.Service)+" was guessed from its next stop")
+		return correctSpineOrientationByNextStop(inputSpine, selection, nodes, relations)
+	}
+
+	spineBearing := geo.Bearing(inputSpine.Start, inputSpine.End)
+	bearingDifference := linebound.BearingDifference(spineBearing, travelBearing)
+	log.Debug().Msg("travel bearing " + fmt.Sprint(travelBearing) + " and spine bearing " + fmt.Sprint(spineBearing) + " differ by " + fmt.Sprint(bearingDifference))
+
+	// if the spine points in the direction of travel, its end is at the front of the train
+	if math.Abs(bearingDifference) < 90 {
+		inputSpine.Start, inputSpine.End = inputSpine.End, inputSpine.Start
+		log.Debug().Msg("switching around")
+	} else {
+		log.Debug().Msg("not switching around")
+	}
+
+	log.Debug().Msg("updated platform spine: " + fmt.Sprint(inputSpine))
+
+	return inputSpine
 }
 
-func correctSpineOrientation(inputSpine models.PlatformSpine, selection models.PlatformAndServiceSelection, nodes map[osm.NodeID]*osm.Node, relations map[osm.RelationID]*osm.Relation) models.PlatformSpine {
+// Orients the spine by comparing the distances of its ends to the next stop of the service.
+// This doesn't work on curved lines, at termini or on loops and is only used if the route ways don't give a direction.
+func correctSpineOrientationByNextStop(inputSpine models.PlatformSpine, selection models.PlatformAndServiceSelection, nodes *nodestore.Store, relations map[osm.RelationID]*osm.Relation) models.PlatformSpine {
 	serviceObject := relations[selection.Service]
 
 	// fmt.Println(serviceObject)
-	fmt.Println(selection.Platform.FeatureID())
+	log.Debug().Msg("searching the next stop after platform " + fmt.Sprint(selection.Platform.FeatureID()))
 
 	foundSelectedPlatform := false
 
@@ -852,7 +1947,11 @@ func correctSpineOrientation(inputSpine models.PlatformSpine, selection models.P
 		if err != nil {
 			log.Err(err).Msg("can't get NodeID of next stop since it is not of type node")
 		}
-		node := nodes[nextStopNodeID]
+		node := nodes.Node(nextStopNodeID)
+		if node == nil {
+			log.Warn().Msg("next stop " + fmt.Sprint(nextStopNodeID) + " is outside the loaded data, keeping the spine orientation")
+			return inputSpine
+		}
 		nextStopPoint = linebound.NodeToPoint(*node)
 	}
 
@@ -881,7 +1980,22 @@ func correctSpineOrientation(inputSpine models.PlatformSpine, selection models.P
 	return inputSpine
 }
 
-func getPlatformNumberOfService(platformID osm.ElementID, nodes map[osm.NodeID]*osm.Node, ways map[osm.WayID]*osm.Way, relations map[osm.RelationID]*osm.Relation, service osm.Relation) (string, error) {
+// returns the first and last node of the way that are part of the data, clipping can cut off the ends of a way
+func wayEndPoints(way *osm.Way, nodes *nodestore.Store) (orb.Point, orb.Point, error) {
+	var points []orb.Point
+	for _, wayNode := range way.Nodes {
+		node := nodes.Node(wayNode.ID)
+		if node != nil {
+			points = append(points, linebound.NodeToPoint(*node))
+		}
+	}
+	if len(points) < 2 {
+		return orb.Point{}, orb.Point{}, errors.New("way " + fmt.Sprint(way.ID) + " has less than two nodes in the loaded data")
+	}
+	return points[0], points[len(points)-1], nil
+}
+
+func getPlatformNumberOfService(platformID osm.ElementID, nodes *nodestore.Store, ways map[osm.WayID]*osm.Way, relations map[osm.RelationID]*osm.Relation, service osm.Relation) (string, error) {
 	platformType, err := platformID.Type()
 	if err != nil {
 		log.Err(err).Msg("platform " + fmt.Sprint(platformID) + " has unknown type")
@@ -895,14 +2009,20 @@ func getPlatformNumberOfService(platformID osm.ElementID, nodes map[osm.NodeID]*
 		if err != nil {
 			log.Err(err).Msg("platform " + fmt.Sprint(platformID) + " is of type way but does not have a WayID")
 		}
-		platform := ways[wayID]
+		platform, exists := ways[wayID]
+		if !exists {
+			return "", errors.New("platform " + fmt.Sprint(platformID) + " isn't part of the loaded data")
+		}
 		platformName = platform.Tags.Find("name")
 	case "relation":
 		relationID, err := platformID.RelationID()
 		if err != nil {
 			log.Err(err).Msg("platform " + fmt.Sprint(platformID) + " is of type relation but does not have a RelationID")
 		}
-		platform := relations[relationID]
+		platform, exists := relations[relationID]
+		if !exists {
+			return "", errors.New("platform " + fmt.Sprint(platformID) + " isn't part of the loaded data")
+		}
 		platformName = platform.Tags.Find("name")
 	default:
 		errorMessage := "platform " + fmt.Sprint(platformID) + " is neither way or relation"
@@ -924,9 +2044,9 @@ func getPlatformNumberOfService(platformID osm.ElementID, nodes map[osm.NodeID]*
 			if err != nil {
 				log.Err(err).Msg("member " + fmt.Sprint(member.ElementID()) + " is of type node but does not have a node id")
 			}
-			stopPosition = nodes[nodeID]
+			stopPosition = nodes.Node(nodeID)
 			if stopPosition == nil {
-				log.Debug().Msg("node " + fmt.Sprint(nodeID) + " cannot be found in nodes map")
+				log.Debug().Msg("node " + fmt.Sprint(nodeID) + " cannot be found in the node store")
 			} else {
 				stopPositionName := stopPosition.Tags.Find("name")
 				log.Debug().Msg("stop position id: " + fmt.Sprint(stopPosition.ElementID()) + " with name " + fmt.Sprint(stopPositionName))
@@ -939,6 +2059,12 @@ func getPlatformNumberOfService(platformID osm.ElementID, nodes map[osm.NodeID]*
 
 	var platformNumberString string
 
+	if stopPosition == nil {
+		errMessage := "service " + fmt.Sprint(service.ID) + " has no stop position for platform " + fmt.Sprint(platformID)
+		log.Error().Msg(errMessage)
+		return "", errors.New(errMessage)
+	}
+
 	ref := stopPosition.Tags.Find("ref")
 	localRef := stopPosition.Tags.Find("local_ref")
 
@@ -958,23 +2084,37 @@ func getPlatformNumberOfService(platformID osm.ElementID, nodes map[osm.NodeID]*
 	return platformNumberString, nil
 }
 
-func shortestPathBetweenArrayOfNodes(sourceNodes []osm.NodeID, targetNodes []osm.NodeID, g *simple.WeightedDirectedGraph, loadingContainer *ui.LoadingScreenWithTextWidget) ([]graph.Node, float64) {
+func shortestPathBetweenArrayOfNodes(cancelCtx context.Context, sourceNodes []osm.NodeID, targetNodes []osm.NodeID, g *simple.WeightedDirectedGraph, status func(string)) ([]graph.Node, float64, error) {
 	var shortestPath []graph.Node
-	var shortestWeight float64
+	shortestWeight := math.Inf(1)
 	totalRouteAmount := len(sourceNodes) * len(targetNodes)
 	for sourceIndex, sourceID := range sourceNodes {
+		// a shortest path tree of a whole city takes a while, so cancelling is checked before each one
+		if err := cancelCtx.Err(); err != nil {
+			return nil, 0, err
+		}
+		// only nodes on walkable ways are part of the graph
+		sourceNode := g.Node(int64(sourceID))
+		if sourceNode == nil {
+			log.Debug().Msg("source node " + fmt.Sprint(sourceID) + " isn't part of the walking graph")
+			continue
+		}
 		// Compute the shortest path tree from the source node
-		shortest := path.DijkstraFrom(g.Node(int64(sourceID)), g)
+		shortest := path.DijkstraFrom(sourceNode, g)
 
 		// Extract shortest paths to the destination nodes
 		for destIndex, destID := range targetNodes {
-			if path, weight := shortest.To(int64(destID)); len(path) > 0 {
+			if path, weight := shortest.To(int64(destID)); len(path) > 0 && weight < shortestWeight {
 				shortestPath = path
 				shortestWeight = weight
 			}
-			currentRouteIndex := (sourceIndex + 1) * (destIndex + 1)
-			loadingContainer.SetText("calculating route: " + fmt.Sprint(currentRouteIndex) + "/" + fmt.Sprint(totalRouteAmount))
+			currentRouteIndex := sourceIndex*len(targetNodes) + destIndex + 1
+			status("calculating route: " + fmt.Sprint(currentRouteIndex) + "/" + fmt.Sprint(totalRouteAmount))
 		}
 	}
-	return shortestPath, shortestWeight
+	// the weight ends up in the result, so it is 0 instead of infinite if no path was found
+	if shortestPath == nil {
+		return nil, 0, nil
+	}
+	return shortestPath, shortestWeight, nil
 }
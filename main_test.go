@@ -1,89 +1,490 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
-	"context"
+	"github.com/jkulzer/platform-router/export"
+	"github.com/jkulzer/platform-router/ingest"
 	"github.com/jkulzer/platform-router/linebound"
-	"github.com/jkulzer/platform-router/ui"
-	"gonum.org/v1/gonum/graph/simple"
+	"github.com/jkulzer/platform-router/models"
+	"github.com/jkulzer/platform-router/nodestore"
 
+	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/jkulzer/osm"
-	"github.com/jkulzer/osm/osmpbf"
-	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gonum.org/v1/gonum/graph/simple"
 
 	"os"
 )
 
 func BenchmarkShortestPathBetweenArrayOfNodes(b *testing.B) {
-	nodes := make(map[osm.NodeID]*osm.Node)
-	ways := make(map[osm.WayID]*osm.Way)
-	relations := make(map[osm.RelationID]*osm.Relation)
 	file, err := os.Open("./berlin-latest.osm.pbf")
 	if err != nil {
 		panic(err)
 	}
 
-	g := simple.NewWeightedDirectedGraph(1, 0)
-
-	// Create a PBF reader
-	scanner := osmpbf.New(context.Background(), file, 4)
-
-	// Scan and populate the relations map
-	for scanner.Scan() {
-		// Get the next OSM object
-		obj := scanner.Object()
-
-		switch v := obj.(type) {
-		case *osm.Node:
-			nodes[v.ID] = v
-			g.AddNode(simple.Node(v.ID))
-		case *osm.Way:
-			ways[v.ID] = v
-			// iterates through every node on every way
-			nodeListLength := len(v.Nodes)
-			for i, _ := range v.Nodes {
-
-				// creates an edge for every segment of the way
-				/*
-					If this is on the last node, there are no more segments to create
-					(since the number of edges in a series of edges is node count - 1)
-					and the edge creation must be skipped (otherwise array out of bounds)
-				*/
-				if i+1 != nodeListLength {
-					if v.Tags.Find("highway") == "footway" || v.Tags.Find("highway") == "steps" {
-						thisNode := nodes[v.Nodes[i].ID]
-						nextNode := nodes[v.Nodes[i+1].ID]
-						nodeDistance := geo.Distance(linebound.NodeToPoint(*thisNode), linebound.NodeToPoint(*nextNode))
-						// disable routing through elevators
-						if thisNode.Tags.Find("highway") == "elevator" || nextNode.Tags.Find("highway") == "elevator" {
-						} else {
-
-							// TODO: Add very high penalty for walking in the wrong direction of an escalator
-
-							// only allow walking along escalators the right direction
-							if v.Tags.Find("conveying") != "" && v.Tags.Find("conveying") == "forward" {
-								g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(v.Nodes[i].ID), simple.Node(v.Nodes[i+1].ID), nodeDistance*0.5))
-							} else if v.Tags.Find("conveying") != "" && v.Tags.Find("conveying") == "backward" {
-								g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(v.Nodes[i+1].ID), simple.Node(v.Nodes[i].ID), nodeDistance*0.5))
-
-							} else {
-								// if it is only a basic walkway
-								g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(v.Nodes[i].ID), simple.Node(v.Nodes[i+1].ID), nodeDistance))
-								g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(v.Nodes[i+1].ID), simple.Node(v.Nodes[i].ID), nodeDistance))
-							}
-						}
-					}
-				}
-			}
-		case *osm.Relation:
-			relations[v.ID] = v
-		default:
-			// Handle other OSM object types if needed
-		}
+	dataset, err := ingest.Load(context.Background(), file, "berlin-latest.osm.pbf", ingest.DefaultOptions())
+	if err != nil {
+		panic(err)
 	}
-	loadingContainer := ui.NewLoadingScreenWithTextWidget()
+
 	sourceNodes := []osm.NodeID{osm.NodeID(2451641844), osm.NodeID(4170056703), osm.NodeID(4170056702), osm.NodeID(12330904367), osm.NodeID(10846473246)}
 	destNodes := []osm.NodeID{osm.NodeID(4170056704), osm.NodeID(2400549269), osm.NodeID(5063750065), osm.NodeID(2400549255)}
-	shortestPathBetweenArrayOfNodes(sourceNodes, destNodes, g, loadingContainer)
+	shortestPathBetweenArrayOfNodes(context.Background(), sourceNodes, destNodes, dataset.Graph, func(string) {})
+}
+
+func TestShortestPathBetweenArrayOfNodes(t *testing.T) {
+	g := simple.NewWeightedDirectedGraph(0, math.Inf(1))
+	edge := func(from int64, to int64, weight float64) {
+		g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(from), simple.Node(to), weight))
+	}
+	// the last target and the last source are farther away than the first ones
+	edge(1, 2, 10)
+	edge(1, 3, 50)
+	edge(4, 2, 30)
+	edge(4, 3, 40)
+
+	var statuses []string
+	path, weight, err := shortestPathBetweenArrayOfNodes(context.Background(), []osm.NodeID{1, 4}, []osm.NodeID{2, 3}, g, func(status string) {
+		statuses = append(statuses, status)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 2 || path[0].ID() != 1 || path[1].ID() != 2 || weight != 10 {
+		t.Errorf("expected the path from 1 to 2 with weight 10, got %v with weight %f", path, weight)
+	}
+	expectedStatuses := []string{"calculating route: 1/4", "calculating route: 2/4", "calculating route: 3/4", "calculating route: 4/4"}
+	if !slices.Equal(statuses, expectedStatuses) {
+		t.Errorf("expected the progress %v, got %v", expectedStatuses, statuses)
+	}
+
+	// the targets can't be reached from 2
+	path, weight, err = shortestPathBetweenArrayOfNodes(context.Background(), []osm.NodeID{2}, []osm.NodeID{1, 4}, g, func(string) {})
+	if err != nil || path != nil || weight != 0 {
+		t.Errorf("expected no path with weight 0, got %v with weight %f", path, weight)
+	}
+}
+
+// indexes all ways like the map ways of a loaded dataset
+func indexWays(ways map[osm.WayID]*osm.Way, nodes *nodestore.Store) *linebound.WayIndex {
+	mapWays := linebound.NewWayIndex()
+	for _, way := range ways {
+		mapWays.Add(way, nodes)
+	}
+	return mapWays
+}
+
+func TestSummarizePath(t *testing.T) {
+	builder := nodestore.NewBuilder()
+	for id := osm.NodeID(1); id <= 4; id++ {
+		builder.Add(&osm.Node{ID: id, Lon: 13.4, Lat: 52.5 + float64(id)*0.0001})
+	}
+	nodes := builder.Build()
+	ways := map[osm.WayID]*osm.Way{
+		10: {ID: 10, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}}, Tags: osm.Tags{{Key: "highway", Value: "footway"}}},
+		11: {ID: 11, Nodes: osm.WayNodes{{ID: 3}, {ID: 2}}, Tags: osm.Tags{{Key: "highway", Value: "steps"}}},
+		12: {ID: 12, Nodes: osm.WayNodes{{ID: 3}, {ID: 4}}, Tags: osm.Tags{{Key: "highway", Value: "steps"}, {Key: "conveying", Value: "forward"}}},
+	}
+	transfer := models.Transfer{}
+	for id := osm.NodeID(1); id <= 4; id++ {
+		point, _ := nodes.Point(id)
+		transfer.Path = append(transfer.Path, models.PathNode{ID: id, Point: point})
+	}
+	transfer.Path[0].Level = "0"
+	transfer.Path[2].Level = "-1"
+	transfer.Path[3].Level = "-2"
+
+	summarizePath(&transfer, nodes, ways, indexWays(ways, nodes))
+	if transfer.LevelChanges != 2 {
+		t.Errorf("expected 2 level changes, got %d", transfer.LevelChanges)
+	}
+	// the steps are walked against the direction of the way
+	expectedMeans := []models.VerticalMeans{{Kind: models.VerticalMeansSteps, Feature: osm.WayID(11).FeatureID()}, {Kind: models.VerticalMeansEscalator, Feature: osm.WayID(12).FeatureID()}}
+	if !slices.Equal(transfer.VerticalMeans, expectedMeans) {
+		t.Errorf("expected %v, got %v", expectedMeans, transfer.VerticalMeans)
+	}
+	// about 11 m each at walking speed, on the steps and on the escalator
+	expectedSeconds := 11.1/walkingSpeed + 11.1/stepsSpeed + 11.1/escalatorSpeed
+	expectedTime := time.Duration(expectedSeconds * float64(time.Second))
+	if difference := transfer.WalkingTime - expectedTime; difference < -time.Second || difference > time.Second {
+		t.Errorf("expected a walking time of about %s, got %s", expectedTime, transfer.WalkingTime)
+	}
+}
+
+func TestSummarizePathThroughElevator(t *testing.T) {
+	builder := nodestore.NewBuilder()
+	builder.Add(&osm.Node{ID: 1, Lon: 13.4, Lat: 52.5})
+	builder.Add(&osm.Node{ID: 2, Lon: 13.4, Lat: 52.5001, Tags: osm.Tags{{Key: "highway", Value: "elevator"}}})
+	builder.Add(&osm.Node{ID: 3, Lon: 13.4, Lat: 52.5002})
+	nodes := builder.Build()
+	// the elevator connects the footways of both levels
+	ways := map[osm.WayID]*osm.Way{
+		10: {ID: 10, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}}, Tags: osm.Tags{{Key: "highway", Value: "footway"}, {Key: "level", Value: "0"}}},
+		11: {ID: 11, Nodes: osm.WayNodes{{ID: 2}, {ID: 3}}, Tags: osm.Tags{{Key: "highway", Value: "footway"}, {Key: "level", Value: "-1"}}},
+	}
+	transfer := models.Transfer{}
+	for id := osm.NodeID(1); id <= 3; id++ {
+		point, _ := nodes.Point(id)
+		transfer.Path = append(transfer.Path, models.PathNode{ID: id, Point: point})
+	}
+	transfer.Path[0].Level = "0"
+	transfer.Path[2].Level = "-1"
+
+	summarizePath(&transfer, nodes, ways, indexWays(ways, nodes))
+	expectedMeans := []models.VerticalMeans{{Kind: models.VerticalMeansElevator, Feature: osm.NodeID(2).FeatureID()}}
+	if !slices.Equal(transfer.VerticalMeans, expectedMeans) || transfer.LevelChanges != 1 {
+		t.Errorf("expected one level change by elevator, got %d and %v", transfer.LevelChanges, transfer.VerticalMeans)
+	}
+	expectedSeconds := 22.2/walkingSpeed + elevatorSeconds
+	expectedTime := time.Duration(expectedSeconds * float64(time.Second))
+	if difference := transfer.WalkingTime - expectedTime; difference < -time.Second || difference > time.Second {
+		t.Errorf("expected a walking time of about %s, got %s", expectedTime, transfer.WalkingTime)
+	}
+}
+
+func TestCollectMapFeatures(t *testing.T) {
+	builder := nodestore.NewBuilder()
+	points := map[osm.NodeID]orb.Point{
+		// the platform and its outline
+		1: {13.4000, 52.5000}, 2: {13.4010, 52.5000}, 3: {13.4010, 52.5001}, 4: {13.4000, 52.5001},
+		// a track crossing the station without a node near it
+		5: {13.3800, 52.5002}, 6: {13.4200, 52.5002},
+		// a footway a few kilometers away
+		7: {13.5000, 52.5000}, 8: {13.5010, 52.5000},
+	}
+	for id, point := range points {
+		builder.Add(&osm.Node{ID: id, Lon: point.Lon(), Lat: point.Lat()})
+	}
+	nodes := builder.Build()
+	ways := map[osm.WayID]*osm.Way{
+		10: {ID: 10, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}}, Tags: osm.Tags{{Key: "railway", Value: "platform"}}},
+		11: {ID: 11, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 1}}},
+		12: {ID: 12, Nodes: osm.WayNodes{{ID: 5}, {ID: 6}}, Tags: osm.Tags{{Key: "railway", Value: "subway"}}},
+		13: {ID: 13, Nodes: osm.WayNodes{{ID: 7}, {ID: 8}}, Tags: osm.Tags{{Key: "highway", Value: "footway"}}},
+	}
+	transfer := models.Transfer{
+		Source: models.TransferEnd{Spine: models.PlatformSpine{Start: points[1], End: points[2]}},
+		Dest:   models.TransferEnd{Spine: models.PlatformSpine{Start: points[4], End: points[3]}},
+	}
+
+	features := collectMapFeatures(transfer, nodes, ways, indexWays(ways, nodes), mapset.NewSet[osm.WayID](11))
+	kinds := make(map[models.MapFeatureKind]int)
+	for _, feature := range features {
+		kinds[feature.Kind]++
+	}
+	expected := map[models.MapFeatureKind]int{models.MapFeaturePlatform: 2, models.MapFeatureTrack: 1}
+	if !maps.Equal(kinds, expected) {
+		t.Errorf("expected %v, got %v", expected, kinds)
+	}
+}
+
+// the JSON written with -json has to match the published schema
+func TestWriteExportJSON(t *testing.T) {
+	selection := models.PlatformAndServiceSelection{Platform: osm.WayID(1).ElementID(1), Service: 2}
+	tests := []struct {
+		name     string
+		transfer models.Transfer
+	}{
+		{"populated", models.Transfer{
+			Source: models.TransferEnd{
+				Selection:     selection,
+				PlatformName:  "Warschauer Straße",
+				PlatformRef:   "1",
+				ServiceName:   "U1: Uhlandstraße => Warschauer Straße",
+				ServiceRef:    "U1",
+				ServiceColour: "#7DAD4C",
+				Spine:         models.PlatformSpine{Start: orb.Point{13.4490, 52.5050}, End: orb.Point{13.4490, 52.5060}},
+				Side:          models.PlatformSideRight,
+				StoppingRange: orb.LineString{{13.4491, 52.5050}, {13.4491, 52.5060}},
+				Exit:          models.PathNode{ID: 10, Point: orb.Point{13.4489, 52.5052}, Level: "0"},
+				Door:          orb.Point{13.4490, 52.5052},
+				DoorDistance:  22.2,
+				SpineLength:   111.2,
+			},
+			Dest: models.TransferEnd{
+				Selection:   models.PlatformAndServiceSelection{Platform: osm.RelationID(3).ElementID(1), Service: 4, Edge: 5},
+				ServiceRef:  "U2",
+				Spine:       models.PlatformSpine{Start: orb.Point{13.4480, 52.5055}, End: orb.Point{13.4470, 52.5055}},
+				Side:        models.PlatformSideLeft,
+				Exit:        models.PathNode{ID: 12, Point: orb.Point{13.4481, 52.5054}, Level: "-1"},
+				Door:        orb.Point{13.4481, 52.5055},
+				SpineLength: 67.8,
+			},
+			Path: []models.PathNode{
+				{ID: 10, Point: orb.Point{13.4489, 52.5052}, Level: "0"},
+				{ID: 11, Point: orb.Point{13.4485, 52.5053}},
+				{ID: 12, Point: orb.Point{13.4481, 52.5054}, Level: "-1"},
+			},
+			PathDistance:  56.4,
+			PathWeight:    50.1,
+			WalkingTime:   97 * time.Second,
+			LevelChanges:  1,
+			VerticalMeans: []models.VerticalMeans{{Kind: models.VerticalMeansElevator, Feature: osm.NodeID(11).FeatureID()}},
+			Warnings:      []string{"the side of the dest platform is unknown"},
+			DataTimestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		}},
+		// nothing was found
+		{"empty", models.Transfer{
+			Source:   models.TransferEnd{Selection: selection},
+			Dest:     models.TransferEnd{Selection: models.PlatformAndServiceSelection{Platform: osm.WayID(3).ElementID(1), Service: 4}},
+			Warnings: []string{"no walking path was found between the platforms"},
+		}},
+	}
+	formatIndex := slices.IndexFunc(export.Formats, func(format export.Format) bool {
+		return format.Name == "JSON"
+	})
+	if formatIndex == -1 {
+		t.Fatal("JSON isn't an export format")
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+	if err := compiler.AddResource("result.schema.json", bytes.NewReader(export.ResultSchema)); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := compiler.Compile("result.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "transfer.json")
+			if err := writeExport(path, export.Formats[formatIndex], test.transfer); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result any
+			if err := json.Unmarshal(data, &result); err != nil {
+				t.Fatal(err)
+			}
+			if err := schema.Validate(result); err != nil {
+				t.Errorf("written JSON doesn't match the schema: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	dataset := &ingest.Dataset{
+		Ways: map[osm.WayID]*osm.Way{789: {ID: 789, Version: 2}},
+		Relations: map[osm.RelationID]*osm.Relation{
+			123: {ID: 123, Version: 3},
+			456: {ID: 456, Version: 1},
+		},
+	}
+	for _, text := range []string{"relation/123,456", "relation/123,456,way/789", "way/789,456"} {
+		selection, err := parseSelection(text, dataset)
+		if err != nil {
+			t.Fatalf("parsing %v failed: %v", text, err)
+		}
+		// a saved transfer is written with formatSelection and read again with parseSelection
+		if formatted := formatSelection(selection); formatted != text {
+			t.Errorf("expected %v to be formatted the same, got %v", text, formatted)
+		}
+	}
+	selection, err := parseSelection("relation/123,relation/456,way/789", dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := models.PlatformAndServiceSelection{Platform: osm.RelationID(123).ElementID(3), Service: 456, Edge: 789}
+	if selection != expected {
+		t.Errorf("expected %v, got %v", expected, selection)
+	}
+	for _, text := range []string{"relation/123", "relation/123,456,way/789,1", "relation/123,456,way/abc", "relation/123,999"} {
+		if _, err := parseSelection(text, dataset); err == nil {
+			t.Errorf("expected %v to be rejected", text)
+		}
+	}
+}
+
+func TestDescribeDataset(t *testing.T) {
+	fileTime := time.Date(2024, 5, 2, 9, 30, 0, 0, time.Local)
+	dataTimestamp := time.Date(2024, 5, 1, 20, 21, 3, 0, time.FixedZone("CEST", 2*60*60))
+	tests := []struct {
+		fileTime      time.Time
+		dataTimestamp time.Time
+		expected      string
+	}{
+		{fileTime, dataTimestamp, "Dataset berlin.osm.pbf, file from 2024-05-02 09:30, OSM data as of 2024-05-01 18:21 UTC"},
+		// Overpass skel output has no timestamps and a picked file might not be local
+		{time.Time{}, time.Time{}, "Dataset berlin.osm.pbf, the data has no OSM timestamps"},
+	}
+	for _, test := range tests {
+		if description := describeDataset("berlin.osm.pbf", test.fileTime, test.dataTimestamp); description != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, description)
+		}
+	}
+}
+
+func TestDeterminePlatformSide(t *testing.T) {
+	// a track running east with the nodes 1 to 3 and a service along it
+	builder := nodestore.NewBuilder()
+	builder.Add(&osm.Node{ID: 1, Lon: 13.400, Lat: 52.5})
+	builder.Add(&osm.Node{ID: 2, Lon: 13.401, Lat: 52.5})
+	builder.Add(&osm.Node{ID: 3, Lon: 13.402, Lat: 52.5})
+	nodes := builder.Build()
+	ways := map[osm.WayID]*osm.Way{
+		1: {ID: 1, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}}},
+		2: {ID: 2, Nodes: osm.WayNodes{{ID: 2}, {ID: 3}}},
+	}
+	relations := map[osm.RelationID]*osm.Relation{
+		10: {ID: 10, Members: osm.Members{{Type: osm.TypeWay, Ref: 1}, {Type: osm.TypeWay, Ref: 2}}},
+		11: {ID: 11, Members: osm.Members{{Type: osm.TypeWay, Ref: 2}, {Type: osm.TypeWay, Ref: 1}}},
+	}
+	north := orb.Point{13.401, 52.50004}
+	south := orb.Point{13.401, 52.49996}
+	tests := []struct {
+		name     string
+		service  osm.RelationID
+		centroid orb.Point
+		expected models.PlatformSide
+	}{
+		{"platform north of an eastbound train", 10, north, models.PlatformSideLeft},
+		{"platform south of an eastbound train", 10, south, models.PlatformSideRight},
+		{"platform north of a westbound train", 11, north, models.PlatformSideRight},
+		{"platform south of a westbound train", 11, south, models.PlatformSideLeft},
+		{"platform on top of the track", 10, orb.Point{13.401, 52.5}, models.PlatformSideUnknown},
+	}
+	for _, test := range tests {
+		spine := models.PlatformSpine{Start: orb.Point{13.4005, test.centroid.Lat()}, End: orb.Point{13.4015, test.centroid.Lat()}}
+		selection := models.PlatformAndServiceSelection{Platform: osm.WayID(100).ElementID(1), Service: test.service}
+		if side := determinePlatformSide(spine, selection, test.centroid, nodes, ways, relations); side != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, side)
+		}
+	}
+
+	// without a track close to the platform the side is unknown
+	far := orb.Point{13.401, 52.51}
+	spine := models.PlatformSpine{Start: orb.Point{13.4005, far.Lat()}, End: orb.Point{13.4015, far.Lat()}}
+	selection := models.PlatformAndServiceSelection{Platform: osm.WayID(100).ElementID(1), Service: 10}
+	if side := determinePlatformSide(spine, selection, far, nodes, ways, relations); side != models.PlatformSideUnknown {
+		t.Errorf("expected an unknown side for a platform far away from the track, got %v", side)
+	}
+}
+
+func TestSharedDataset(t *testing.T) {
+	first := &ingest.Dataset{Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	second := &ingest.Dataset{}
+	var cancelled int
+	shared := &sharedDataset{cancelQueries: func() { cancelled++ }}
+	shared.replace(loadedDataset{dataset: first, name: "first.osm.pbf"})
+
+	// a query keeps the dataset until it releases it, replacing it has to wait
+	dataset, release := shared.acquire()
+	replaced := make(chan bool)
+	go func() {
+		shared.replace(loadedDataset{dataset: second, name: "second.osm.pbf"})
+		replaced <- true
+	}()
+	select {
+	case <-replaced:
+		t.Fatal("the dataset was replaced while a query used it")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if dataset != first {
+		t.Errorf("expected the query to keep the first dataset")
+	}
+	release()
+	<-replaced
+	if cancelled != 2 {
+		t.Errorf("expected the running queries to be cancelled before every replacement, got %d", cancelled)
+	}
+
+	shared.change(func(dataset *ingest.Dataset) {
+		dataset.ReplicationSequence = 42
+	})
+	dataset, release = shared.acquire()
+	if dataset != second || dataset.ReplicationSequence != 42 {
+		t.Errorf("expected the change to be applied to the second dataset")
+	}
+	release()
+	if description := shared.describe(); description != "Dataset second.osm.pbf, the data has no OSM timestamps" {
+		t.Errorf("unexpected description %q", description)
+	}
+}
+
+// the stop positions and the track of both services are outside of the clipped region
+const clippedStation = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="hand edited">
+  <node id="1" version="1" lat="52.5000000" lon="13.4000000"><tag k="level" v="-1"/></node>
+  <node id="2" version="1" lat="52.5000000" lon="13.4010000"><tag k="level" v="-1"/></node>
+  <node id="3" version="1" lat="52.5002000" lon="13.4010000"><tag k="level" v="-1"/></node>
+  <node id="4" version="1" lat="52.5002000" lon="13.4000000"><tag k="level" v="-1"/></node>
+  <node id="50" version="1" lat="52.5001000" lon="13.3900000"><tag k="public_transport" v="stop_position"/></node>
+  <node id="51" version="1" lat="52.5001000" lon="13.4200000"><tag k="public_transport" v="stop_position"/></node>
+  <way id="30" version="1"><nd ref="1"/><nd ref="2"/><tag k="railway" v="platform"/><tag k="name" v="Teststraße"/></way>
+  <way id="31" version="1"><nd ref="3"/><nd ref="4"/><tag k="railway" v="platform"/><tag k="name" v="Teststraße"/></way>
+  <way id="32" version="1"><nd ref="2"/><nd ref="3"/><tag k="highway" v="footway"/></way>
+  <way id="40" version="1"><nd ref="50"/><nd ref="51"/><tag k="railway" v="subway"/></way>
+  <relation id="100" version="1">
+    <member type="node" ref="50" role="stop"/>
+    <member type="way" ref="30" role="platform"/>
+    <member type="node" ref="51" role="stop"/>
+    <member type="way" ref="40" role=""/>
+    <tag k="type" v="route"/><tag k="route" v="subway"/><tag k="ref" v="U1"/>
+  </relation>
+  <relation id="101" version="1">
+    <member type="node" ref="51" role="stop"/>
+    <member type="way" ref="31" role="platform"/>
+    <member type="node" ref="50" role="stop"/>
+    <member type="way" ref="40" role=""/>
+    <tag k="type" v="route"/><tag k="route" v="subway"/><tag k="ref" v="U1"/>
+  </relation>
+</osm>`
+
+func TestComputeTransferOnClippedData(t *testing.T) {
+	bbox, err := ingest.ParseBBox("13.3995,52.4995,13.4015,52.5005")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clip, err := ingest.NewClip(bbox, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataset, err := ingest.Load(context.Background(), strings.NewReader(clippedStation), "clipped.osm", ingest.Options{Workers: 2, Clip: clip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataset.Nodes.Has(51) || dataset.Ways[40] != nil {
+		t.Fatal("expected the stops and the track to be clipped")
+	}
+
+	compute := func(source models.PlatformAndServiceSelection, dest models.PlatformAndServiceSelection) (models.Transfer, error) {
+		return computeTransfer(context.Background(), models.AppContext{}, func(string) {}, dataset.Nodes, dataset.Ways, dataset.Relations, dataset.TrainTracks, dataset.MapWays, dataset.FootWays, dataset.Graph, dataset.Timestamp, source, dest)
+	}
+	source := models.PlatformAndServiceSelection{Platform: osm.WayID(30).ElementID(1), Service: 100}
+	dest := models.PlatformAndServiceSelection{Platform: osm.WayID(31).ElementID(1), Service: 101}
+
+	transfer, err := compute(source, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfer.Path) == 0 {
+		t.Errorf("expected a path between the platforms")
+	}
+	if transfer.Source.Side != models.PlatformSideUnknown {
+		t.Errorf("expected the side to be unknown without the track, got %s", transfer.Source.Side)
+	}
+
+	// a service or platform of a saved transfer can be missing entirely
+	if _, err := compute(source, models.PlatformAndServiceSelection{Platform: dest.Platform, Service: 102}); err == nil {
+		t.Errorf("expected an error for a missing service")
+	}
+	if _, err := compute(source, models.PlatformAndServiceSelection{Platform: osm.WayID(33).ElementID(1), Service: 101}); err == nil {
+		t.Errorf("expected an error for a missing platform")
+	}
 }
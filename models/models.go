@@ -1,11 +1,15 @@
 package models
 
 import (
+	"time"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"github.com/fatih/color"
 	"github.com/rs/zerolog"
 
+	"github.com/jkulzer/platform-router/saved"
+
 	"github.com/paulmach/orb"
 
 	"github.com/jkulzer/osm"
@@ -15,6 +19,8 @@ type AppContext struct {
 	Log    zerolog.Logger
 	Window fyne.Window
 	Tabs   *container.AppTabs
+	// Saved records the transfers run in the window, nil without one
+	Saved *saved.Store
 }
 
 type PlatformItem struct {
@@ -25,6 +31,8 @@ type PlatformItem struct {
 type PlatformAndServiceSelection struct {
 	Platform osm.ElementID
 	Service  osm.RelationID
+	// Edge is the platform edge to use if the platform is a relation with several of them, 0 if it is chosen while routing
+	Edge osm.WayID
 }
 
 type PlatformList struct {
@@ -47,3 +55,122 @@ type PlatformSpine struct {
 	Start orb.Point
 	End   orb.Point
 }
+
+// PlatformSide is the side of the train, in the direction of travel, on which the platform lies
+type PlatformSide int
+
+const (
+	PlatformSideUnknown PlatformSide = iota
+	PlatformSideLeft
+	PlatformSideRight
+)
+
+func (s PlatformSide) String() string {
+	switch s {
+	case PlatformSideLeft:
+		return "left"
+	case PlatformSideRight:
+		return "right"
+	default:
+		return "unknown"
+	}
+}
+
+// MapFeatureKind is what a way drawn on the station map is
+type MapFeatureKind int
+
+const (
+	MapFeaturePlatform MapFeatureKind = iota
+	MapFeatureTrack
+	MapFeatureFootway
+	MapFeatureSteps
+	MapFeatureEscalator
+)
+
+// MapFeature is a way drawn on the station map, platform areas are closed lines
+type MapFeature struct {
+	Kind MapFeatureKind
+	Line orb.LineString
+}
+
+// PathNode is a node of the walking path
+type PathNode struct {
+	ID    osm.NodeID
+	Point orb.Point
+	// Level is the level tag of the node, empty if it has none
+	Level string
+}
+
+// TransferEnd is the platform on one side of a transfer and where to stand on it
+type TransferEnd struct {
+	Selection PlatformAndServiceSelection
+	// PlatformName is the name tag of the platform, usually the name of the station
+	PlatformName string
+	PlatformRef  string
+	ServiceName  string
+	ServiceRef   string
+	// ServiceColour is the colour tag of the service, empty if it has none
+	ServiceColour string
+	// Spine is oriented so that Start is where the front of the train stops
+	Spine PlatformSpine
+	Side  PlatformSide
+	// StoppingRange is the part of the service's track alongside the spine, nil if the track isn't close enough
+	StoppingRange orb.LineString
+	// TrackBuffers are the bounds around the service's track segments next to the platform, used for finding the spine
+	TrackBuffers []orb.Ring
+	// Exit is the first or last node of the path that has a level, so where the path leaves or enters the platform
+	Exit PathNode
+	// Door is the point on the spine closest to the exit
+	Door orb.Point
+	// DoorDistance is the distance in meters from the start of the spine to the door
+	DoorDistance float64
+	// SpineLength is the length of the spine in meters
+	SpineLength float64
+}
+
+// DoorFraction returns how far along the platform the door is, from 0 at the front of the train to 1
+func (e TransferEnd) DoorFraction() float64 {
+	if e.SpineLength == 0 {
+		return 0
+	}
+	return e.DoorDistance / e.SpineLength
+}
+
+// Transfer is the result of routing between two platforms
+type Transfer struct {
+	Source TransferEnd
+	Dest   TransferEnd
+	Path   []PathNode
+	// PathDistance is the length of the walking path in meters
+	PathDistance float64
+	// PathWeight is the weight of the path in the walking graph, escalators make it shorter than the distance
+	PathWeight float64
+	// ClosePoints are the platform nodes that were found close to the tracks while looking for spines
+	ClosePoints []orb.Point
+	// WalkingTime is the estimated time for walking the path, slower on steps
+	WalkingTime time.Duration
+	// LevelChanges is how often the level changes between the nodes of the path that have one
+	LevelChanges int
+	// VerticalMeans are the steps, escalators and elevators along the path in the order they are used
+	VerticalMeans []VerticalMeans
+	// Warnings describe everything that was guessed or not found while computing the transfer
+	Warnings []string
+	// DataTimestamp is the time of the newest object in the data the transfer was computed from, zero if unknown
+	DataTimestamp time.Time
+}
+
+// VerticalMeansKind is how the levels are changed
+type VerticalMeansKind string
+
+const (
+	VerticalMeansSteps     VerticalMeansKind = "steps"
+	VerticalMeansEscalator VerticalMeansKind = "escalator"
+	VerticalMeansElevator  VerticalMeansKind = "elevator"
+)
+
+// VerticalMeans is a way or node of the path used for changing levels
+type VerticalMeans struct {
+	Kind VerticalMeansKind
+	// Feature is the way of steps and escalators and the node of elevators
+	Feature osm.FeatureID
+}
@@ -0,0 +1,253 @@
+package nodestore
+
+import (
+	"sort"
+
+	"github.com/jkulzer/osm"
+	"github.com/paulmach/orb"
+)
+
+// coordinates are stored as fixed point numbers with 7 decimal places, the same precision the OSM database uses
+const coordinateScale = 1e7
+
+// Store keeps the nodes of a dataset in a compact columnar layout.
+// IDs are kept in a sorted array with the packed coordinates in arrays of the same order,
+// tags are only kept for the few nodes where they matter for routing (see KeepsTags).
+// A Store is read only once built and is safe for concurrent use.
+type Store struct {
+	ids  []osm.NodeID
+	lats []int32
+	lons []int32
+	tags map[osm.NodeID]osm.Tags
+}
+
+// KeepsTags reports if the tags of a node are kept in the store.
+// These are elevators, nodes with a level, stop positions and entrances.
+func KeepsTags(tags osm.Tags) bool {
+	return tags.Find("highway") == "elevator" ||
+		tags.Find("level") != "" ||
+		tags.Find("public_transport") == "stop_position" ||
+		tags.Find("entrance") != "" ||
+		tags.Find("railway") == "subway_entrance"
+}
+
+func toFixed(degrees float64) int32 {
+	if degrees < 0 {
+		return int32(degrees*coordinateScale - 0.5)
+	}
+	return int32(degrees*coordinateScale + 0.5)
+}
+
+func fromFixed(fixed int32) float64 {
+	return float64(fixed) / coordinateScale
+}
+
+func (s *Store) index(id osm.NodeID) (int, bool) {
+	index := sort.Search(len(s.ids), func(i int) bool { return s.ids[i] >= id })
+	return index, index < len(s.ids) && s.ids[index] == id
+}
+
+// Len returns the number of nodes in the store
+func (s *Store) Len() int {
+	return len(s.ids)
+}
+
+// Has checks if the node is in the store
+func (s *Store) Has(id osm.NodeID) bool {
+	_, exists := s.index(id)
+	return exists
+}
+
+// Point returns the location of a node
+func (s *Store) Point(id osm.NodeID) (orb.Point, bool) {
+	index, exists := s.index(id)
+	if !exists {
+		return orb.Point{}, false
+	}
+	return orb.Point{fromFixed(s.lons[index]), fromFixed(s.lats[index])}, true
+}
+
+// Tags returns the tags of a node, which are empty if the node isn't one of the nodes whose tags are kept
+func (s *Store) Tags(id osm.NodeID) osm.Tags {
+	return s.tags[id]
+}
+
+// Node reconstructs a node with its ID, location and kept tags. It returns nil if the node isn't in the store.
+func (s *Store) Node(id osm.NodeID) *osm.Node {
+	index, exists := s.index(id)
+	if !exists {
+		return nil
+	}
+	return &osm.Node{
+		ID:   id,
+		Lat:  fromFixed(s.lats[index]),
+		Lon:  fromFixed(s.lons[index]),
+		Tags: s.tags[id],
+	}
+}
+
+// TaggedNodes returns the IDs of all nodes whose tags are kept
+func (s *Store) TaggedNodes() []osm.NodeID {
+	ids := make([]osm.NodeID, 0, len(s.tags))
+	for id := range s.tags {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// WithChanges returns a new store with the updated nodes added or replaced and the deleted nodes removed.
+// The store itself isn't modified, so it can still be read while the changes are applied.
+func (s *Store) WithChanges(updated []*osm.Node, deleted []osm.NodeID) *Store {
+	builder := NewBuilder()
+	for _, node := range updated {
+		builder.Add(node)
+	}
+	// tags of updated nodes that aren't relevant anymore are dropped by the merge
+	changed := Merge(s, builder.Build())
+
+	if len(deleted) == 0 {
+		return changed
+	}
+	deletedIDs := make(map[osm.NodeID]bool, len(deleted))
+	for _, id := range deleted {
+		deletedIDs[id] = true
+		delete(changed.tags, id)
+	}
+	kept := 0
+	for i, id := range changed.ids {
+		if deletedIDs[id] {
+			continue
+		}
+		changed.ids[kept] = id
+		changed.lats[kept] = changed.lats[i]
+		changed.lons[kept] = changed.lons[i]
+		kept++
+	}
+	changed.ids = changed.ids[:kept]
+	changed.lats = changed.lats[:kept]
+	changed.lons = changed.lons[:kept]
+	return changed
+}
+
+// Builder collects nodes for a Store. It is not safe for concurrent use.
+type Builder struct {
+	store  *Store
+	sorted bool
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		store:  &Store{tags: make(map[osm.NodeID]osm.Tags)},
+		sorted: true,
+	}
+}
+
+// Add appends a node to the store that is being built
+func (b *Builder) Add(node *osm.Node) {
+	s := b.store
+	if len(s.ids) > 0 && s.ids[len(s.ids)-1] >= node.ID {
+		b.sorted = false
+	}
+	s.ids = append(s.ids, node.ID)
+	s.lats = append(s.lats, toFixed(node.Lat))
+	s.lons = append(s.lons, toFixed(node.Lon))
+	// a node added again replaces the tags of the earlier version, even if it has none that are kept
+	if KeepsTags(node.Tags) {
+		s.tags[node.ID] = node.Tags
+	} else {
+		delete(s.tags, node.ID)
+	}
+}
+
+// Len returns the number of nodes added so far
+func (b *Builder) Len() int {
+	return len(b.store.ids)
+}
+
+// Build sorts the collected nodes and returns the finished store. The builder must not be used afterwards.
+// If a node was added multiple times, the last one is kept.
+func (b *Builder) Build() *Store {
+	s := b.store
+	b.store = nil
+	if !b.sorted {
+		sort.Stable(byID{s})
+	}
+	// removes duplicates, keeping the last version added
+	kept := 0
+	for i := range s.ids {
+		if i+1 < len(s.ids) && s.ids[i] == s.ids[i+1] {
+			continue
+		}
+		s.ids[kept] = s.ids[i]
+		s.lats[kept] = s.lats[i]
+		s.lons[kept] = s.lons[i]
+		kept++
+	}
+	s.ids = s.ids[:kept:kept]
+	s.lats = s.lats[:kept:kept]
+	s.lons = s.lons[:kept:kept]
+	return s
+}
+
+// Merge combines several stores into one. If a node is in more than one store, the one from the later store is kept.
+func Merge(stores ...*Store) *Store {
+	total := 0
+	for _, store := range stores {
+		total += store.Len()
+	}
+	merged := &Store{
+		ids:  make([]osm.NodeID, 0, total),
+		lats: make([]int32, 0, total),
+		lons: make([]int32, 0, total),
+		tags: make(map[osm.NodeID]osm.Tags),
+	}
+
+	// k-way merge of the sorted stores, positions holds the next index to take from each store
+	positions := make([]int, len(stores))
+	for {
+		next := -1
+		for i, store := range stores {
+			if positions[i] >= store.Len() {
+				continue
+			}
+			if next < 0 || store.ids[positions[i]] < stores[next].ids[positions[next]] {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		store := stores[next]
+		id := store.ids[positions[next]]
+		if len(merged.ids) > 0 && merged.ids[len(merged.ids)-1] == id {
+			// an earlier store had the same node
+			merged.ids = merged.ids[:len(merged.ids)-1]
+			merged.lats = merged.lats[:len(merged.lats)-1]
+			merged.lons = merged.lons[:len(merged.lons)-1]
+		}
+		merged.ids = append(merged.ids, id)
+		merged.lats = append(merged.lats, store.lats[positions[next]])
+		merged.lons = append(merged.lons, store.lons[positions[next]])
+		// the tags come from the same store as the coordinates
+		if tags, exists := store.tags[id]; exists {
+			merged.tags[id] = tags
+		} else {
+			delete(merged.tags, id)
+		}
+		positions[next]++
+	}
+	return merged
+}
+
+// byID sorts the columns of a store by node ID
+type byID struct {
+	*Store
+}
+
+func (s byID) Len() int           { return len(s.ids) }
+func (s byID) Less(i, j int) bool { return s.ids[i] < s.ids[j] }
+func (s byID) Swap(i, j int) {
+	s.ids[i], s.ids[j] = s.ids[j], s.ids[i]
+	s.lats[i], s.lats[j] = s.lats[j], s.lats[i]
+	s.lons[i], s.lons[j] = s.lons[j], s.lons[i]
+}
@@ -0,0 +1,134 @@
+package nodestore
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jkulzer/osm"
+)
+
+func TestStoreLookup(t *testing.T) {
+	builder := NewBuilder()
+	builder.Add(&osm.Node{ID: 30, Lat: 52.5050, Lon: 13.4490})
+	builder.Add(&osm.Node{ID: 10, Lat: -33.8688, Lon: 151.2093, Tags: osm.Tags{{Key: "highway", Value: "elevator"}}})
+	builder.Add(&osm.Node{ID: 20, Lat: 1, Lon: 2, Tags: osm.Tags{{Key: "name", Value: "untracked"}}})
+	// the later version of a node wins
+	builder.Add(&osm.Node{ID: 20, Lat: 52.5, Lon: 13.4})
+	store := builder.Build()
+
+	if store.Len() != 3 {
+		t.Fatalf("expected 3 nodes, got %d", store.Len())
+	}
+	point, exists := store.Point(10)
+	if !exists || math.Abs(point.Lat()+33.8688) > 1e-7 || math.Abs(point.Lon()-151.2093) > 1e-7 {
+		t.Errorf("unexpected point %v for node 10", point)
+	}
+	if point, _ := store.Point(20); point.Lat() != 52.5 {
+		t.Errorf("expected the last version of node 20, got %v", point)
+	}
+	if store.Tags(10).Find("highway") != "elevator" {
+		t.Errorf("tags of elevator node weren't kept")
+	}
+	if store.Tags(20) != nil {
+		t.Errorf("tags of untracked node were kept")
+	}
+	if store.Has(15) || store.Node(15) != nil {
+		t.Errorf("node 15 shouldn't exist")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	first := NewBuilder()
+	second := NewBuilder()
+	for id := osm.NodeID(1); id <= 10; id++ {
+		if id%2 == 0 {
+			first.Add(&osm.Node{ID: id, Lat: float64(id)})
+		} else {
+			second.Add(&osm.Node{ID: id, Lat: float64(id)})
+		}
+	}
+	second.Add(&osm.Node{ID: 4, Lat: 40})
+	store := Merge(first.Build(), second.Build())
+
+	if store.Len() != 10 {
+		t.Fatalf("expected 10 nodes, got %d", store.Len())
+	}
+	for id := osm.NodeID(1); id <= 10; id++ {
+		point, exists := store.Point(id)
+		expected := float64(id)
+		if id == 4 {
+			expected = 40
+		}
+		if !exists || point.Lat() != expected {
+			t.Errorf("node %d: expected lat %v, got %v", id, expected, point.Lat())
+		}
+	}
+}
+
+func TestTagsOfTheNewestVersion(t *testing.T) {
+	elevator := osm.Tags{{Key: "highway", Value: "elevator"}}
+	entrance := osm.Tags{{Key: "entrance", Value: "yes"}}
+
+	// a node added again keeps the tags of its last version, not those of an earlier one
+	builder := NewBuilder()
+	builder.Add(&osm.Node{ID: 1, Tags: elevator})
+	builder.Add(&osm.Node{ID: 2})
+	builder.Add(&osm.Node{ID: 1, Lat: 1})
+	builder.Add(&osm.Node{ID: 2, Lat: 2, Tags: entrance})
+	store := builder.Build()
+	if tags := store.Tags(1); len(tags) != 0 {
+		t.Errorf("expected the tags of node 1 to be dropped with its new version, got %v", tags)
+	}
+	if tags := store.Tags(2); tags.Find("entrance") != "yes" {
+		t.Errorf("expected the tags of the new version of node 2, got %v", tags)
+	}
+
+	// the tags come from the store whose node is kept
+	first := NewBuilder()
+	first.Add(&osm.Node{ID: 3, Tags: elevator})
+	first.Add(&osm.Node{ID: 4})
+	first.Add(&osm.Node{ID: 5, Tags: elevator})
+	second := NewBuilder()
+	second.Add(&osm.Node{ID: 3, Lat: 3})
+	second.Add(&osm.Node{ID: 4, Lat: 4, Tags: entrance})
+	merged := Merge(first.Build(), second.Build())
+	if tags := merged.Tags(3); len(tags) != 0 {
+		t.Errorf("expected node 3 to have the tags of the later store, got %v", tags)
+	}
+	if tags := merged.Tags(4); tags.Find("entrance") != "yes" {
+		t.Errorf("expected node 4 to have the tags of the later store, got %v", tags)
+	}
+	if tags := merged.Tags(5); tags.Find("highway") != "elevator" {
+		t.Errorf("expected node 5 to keep its tags, got %v", tags)
+	}
+	if tagged := merged.TaggedNodes(); len(tagged) != 2 {
+		t.Errorf("expected the nodes 4 and 5 to be tagged, got %v", tagged)
+	}
+}
+
+func TestWithChanges(t *testing.T) {
+	builder := NewBuilder()
+	builder.Add(&osm.Node{ID: 1, Lat: 1, Tags: osm.Tags{{Key: "level", Value: "-1"}}})
+	builder.Add(&osm.Node{ID: 2, Lat: 2})
+	builder.Add(&osm.Node{ID: 3, Lat: 3})
+	store := builder.Build()
+
+	changed := store.WithChanges([]*osm.Node{{ID: 1, Lat: 10}, {ID: 4, Lat: 4}}, []osm.NodeID{2})
+
+	if changed.Len() != 3 || changed.Has(2) {
+		t.Fatalf("expected nodes 1, 3 and 4, got %d nodes", changed.Len())
+	}
+	if point, _ := changed.Point(1); point.Lat() != 10 {
+		t.Errorf("node 1 wasn't moved, lat is %v", point.Lat())
+	}
+	if changed.Tags(1) != nil {
+		t.Errorf("node 1 still has the tags of its old version")
+	}
+	if !changed.Has(4) {
+		t.Errorf("created node 4 is missing")
+	}
+	// the original store stays untouched
+	if store.Len() != 3 || !store.Has(2) || store.Tags(1).Find("level") != "-1" {
+		t.Errorf("original store was modified")
+	}
+}
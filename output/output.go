@@ -0,0 +1,203 @@
+// Package output writes the files produced by queries, by default nothing is written
+package output
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jkulzer/platform-router/export"
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// timestamps in file names sort in the order the queries were made
+const timestampLayout = "20060102-150405"
+
+// Sink is where the artifacts of queries are written to.
+// Without a directory nothing is written, so the zero Sink and a nil Sink can be used to disable output.
+type Sink struct {
+	// Dir is the directory the artifacts are written to, it is created if it doesn't exist
+	Dir string
+	// Debug also writes artifacts only useful for development: the nodes close to the tracks and a CPU profile of the routing
+	Debug bool
+}
+
+// Artifact is a file written for a query
+type Artifact struct {
+	// Kind is e.g. the name of the export format
+	Kind string
+	Path string
+}
+
+// Query collects the artifacts of one query. It has to be finished with either Finish or Abort.
+type Query struct {
+	sink    *Sink
+	station string
+	started time.Time
+	// the CPU profile is written to a temporary file since the name of the query isn't known until it is finished
+	profile *os.File
+}
+
+// Enabled reports if the sink writes anything at all
+func (s *Sink) Enabled() bool {
+	return s != nil && s.Dir != ""
+}
+
+// StartQuery starts collecting the artifacts of a query at the station, with Debug this starts the CPU profile.
+// Only one CPU profile can run at a time, a query started while another one is profiled isn't profiled.
+func (s *Sink) StartQuery(station string) (*Query, error) {
+	query := &Query{sink: s, station: station, started: time.Now()}
+	if !s.Enabled() {
+		return query, nil
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	if !s.Debug {
+		return query, nil
+	}
+	profile, err := os.CreateTemp(s.Dir, ".cpuprofile-*")
+	if err != nil {
+		return nil, err
+	}
+	if err := pprof.StartCPUProfile(profile); err != nil {
+		log.Warn().Err(err).Msg("not profiling query")
+		profile.Close()
+		os.Remove(profile.Name())
+		return query, nil
+	}
+	query.profile = profile
+	return query, nil
+}
+
+// Finish writes the transfer in every export format and the debug artifacts, the written files are returned in that order
+func (q *Query) Finish(transfer models.Transfer) ([]Artifact, error) {
+	if !q.sink.Enabled() {
+		return nil, nil
+	}
+	base := filepath.Join(q.sink.Dir, Name(q.station, transfer, q.started))
+	var artifacts []Artifact
+	for _, format := range export.Formats {
+		path := base + format.Extension
+		if err := writeFile(path, func(file *os.File) error { return format.Write(file, transfer) }); err != nil {
+			q.Abort()
+			return artifacts, err
+		}
+		artifacts = append(artifacts, Artifact{Kind: format.Name, Path: path})
+	}
+	if !q.sink.Debug {
+		return artifacts, nil
+	}
+
+	closeNodesPath := base + "_close-nodes.geojson"
+	err := writeFile(closeNodesPath, func(file *os.File) error {
+		collection := geojson.NewFeatureCollection()
+		collection.Append(geojson.NewFeature(orb.MultiPoint(transfer.ClosePoints)))
+		data, err := collection.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(data)
+		return err
+	})
+	if err != nil {
+		q.Abort()
+		return artifacts, err
+	}
+	artifacts = append(artifacts, Artifact{Kind: "Close nodes", Path: closeNodesPath})
+
+	if q.profile != nil {
+		pprof.StopCPUProfile()
+		profilePath := base + ".cpuprofile"
+		err := q.profile.Close()
+		if err == nil {
+			err = os.Rename(q.profile.Name(), profilePath)
+		}
+		q.profile = nil
+		if err != nil {
+			return artifacts, err
+		}
+		artifacts = append(artifacts, Artifact{Kind: "CPU profile", Path: profilePath})
+	}
+	return artifacts, nil
+}
+
+// Abort stops the CPU profile of a query that failed or was cancelled and removes it
+func (q *Query) Abort() {
+	if q.profile == nil {
+		return
+	}
+	pprof.StopCPUProfile()
+	q.profile.Close()
+	os.Remove(q.profile.Name())
+	q.profile = nil
+}
+
+// Name is the file name of the artifacts of a query without the extension, e.g. "warschauer-straße_u1-to-u2_20241019-153012-042".
+// The name of the source platform is used if the station isn't known.
+func Name(station string, transfer models.Transfer, started time.Time) string {
+	if station == "" {
+		station = transfer.Source.PlatformName
+	}
+	parts := []string{
+		slug(station),
+		slug(serviceName(transfer.Source)) + "-to-" + slug(serviceName(transfer.Dest)),
+		// the milliseconds keep queries made in the same second apart
+		started.Format(timestampLayout) + fmt.Sprintf("-%03d", started.Nanosecond()/int(time.Millisecond)),
+	}
+	if parts[0] == "" {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, "_")
+}
+
+func serviceName(end models.TransferEnd) string {
+	if end.ServiceRef != "" {
+		return end.ServiceRef
+	}
+	if end.ServiceName != "" {
+		return end.ServiceName
+	}
+	return "relation-" + fmt.Sprint(end.Selection.Service)
+}
+
+// slug keeps letters and digits and replaces everything else with single dashes, so names can be used in paths on every system
+func slug(text string) string {
+	var builder strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && builder.Len() > 0 {
+				builder.WriteRune('-')
+			}
+			builder.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return builder.String()
+}
+
+// writeFile creates the file and removes it again if write fails, so no partial artifacts are left behind
+func writeFile(path string, write func(file *os.File) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = errors.Join(write(file), file.Close())
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jkulzer/platform-router/export"
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/paulmach/orb"
+)
+
+func testTransfer() models.Transfer {
+	return models.Transfer{
+		Source: models.TransferEnd{
+			PlatformName: "Warschauer Straße",
+			ServiceRef:   "U1",
+			Spine:        models.PlatformSpine{Start: orb.Point{13.4490, 52.5050}, End: orb.Point{13.4490, 52.5060}},
+			SpineLength:  111.2,
+		},
+		Dest: models.TransferEnd{
+			ServiceName: "S5: Westkreuz => Strausberg Nord",
+			Spine:       models.PlatformSpine{Start: orb.Point{13.4480, 52.5055}, End: orb.Point{13.4470, 52.5055}},
+			SpineLength: 67.8,
+		},
+		ClosePoints: []orb.Point{{13.4490, 52.5050}},
+	}
+}
+
+func TestName(t *testing.T) {
+	started := time.Date(2024, 10, 19, 15, 30, 12, 42*int(time.Millisecond), time.UTC)
+	tests := []struct {
+		station  string
+		expected string
+	}{
+		{"S+U Warschauer Straße", "s-u-warschauer-straße_u1-to-s5-westkreuz-strausberg-nord_20241019-153012-042"},
+		// without a station the source platform is used
+		{"", "warschauer-straße_u1-to-s5-westkreuz-strausberg-nord_20241019-153012-042"},
+	}
+	for _, test := range tests {
+		if name := Name(test.station, testTransfer(), started); name != test.expected {
+			t.Errorf("unexpected name %s", name)
+		}
+	}
+
+	if Name("", testTransfer(), started) == Name("", testTransfer(), started.Add(300*time.Millisecond)) {
+		t.Errorf("expected queries in the same second to have different names")
+	}
+}
+
+func TestSinkDisabled(t *testing.T) {
+	for name, sink := range map[string]*Sink{"nil": nil, "zero": {}, "debug without dir": {Debug: true}} {
+		t.Run(name, func(t *testing.T) {
+			query, err := sink.StartQuery("Warschauer Straße")
+			if err != nil {
+				t.Fatal(err)
+			}
+			artifacts, err := query.Finish(testTransfer())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(artifacts) != 0 {
+				t.Errorf("expected no artifacts, got %v", artifacts)
+			}
+		})
+	}
+}
+
+func TestSinkWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		debug bool
+		// the export formats are always written
+		extraArtifacts int
+	}{
+		{"exports", false, 0},
+		{"debug", true, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "out")
+			sink := &Sink{Dir: dir, Debug: test.debug}
+			query, err := sink.StartQuery("Warschauer Straße")
+			if err != nil {
+				t.Fatal(err)
+			}
+			artifacts, err := query.Finish(testTransfer())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(artifacts) != len(export.Formats)+test.extraArtifacts {
+				t.Fatalf("expected %d artifacts, got %v", len(export.Formats)+test.extraArtifacts, artifacts)
+			}
+			for _, artifact := range artifacts {
+				if _, err := os.Stat(artifact.Path); err != nil {
+					t.Errorf("artifact %s wasn't written: %v", artifact.Kind, err)
+				}
+			}
+			// the temporary profile was renamed, so only the listed artifacts are left
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(artifacts) {
+				t.Errorf("expected %d files in the output directory, got %d", len(artifacts), len(entries))
+			}
+		})
+	}
+}
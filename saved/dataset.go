@@ -0,0 +1,34 @@
+package saved
+
+import (
+	fyne "fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/storage"
+
+	"github.com/rs/zerolog/log"
+)
+
+// the URI of the dataset that was loaded last
+const lastDatasetKey = "last_dataset"
+
+// LastDataset returns the dataset that was loaded last, nil if none was loaded yet
+func (s *Store) LastDataset() fyne.URI {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	text := s.preferences.String(lastDatasetKey)
+	if text == "" {
+		return nil
+	}
+	uri, err := storage.ParseURI(text)
+	if err != nil {
+		log.Err(err).Msg("failed parsing the URI of the last dataset " + text)
+		return nil
+	}
+	return uri
+}
+
+// SetLastDataset remembers the dataset, so it is loaded again on the next start
+func (s *Store) SetLastDataset(uri fyne.URI) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.preferences.SetString(lastDatasetKey, uri.String())
+}
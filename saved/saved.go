@@ -0,0 +1,156 @@
+// Package saved keeps the recent and starred transfers and the last loaded dataset in the preferences of the app, so they can be used again
+package saved
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	fyne "fyne.io/fyne/v2"
+
+	"github.com/rs/zerolog/log"
+)
+
+// the transfers are stored as one JSON document, the preferences only have simple types
+const preferencesKey = "saved_transfers"
+
+// MaxRecent is how many transfers that aren't starred are kept
+const MaxRecent = 10
+
+// Transfer is a query that can be run again
+type Transfer struct {
+	// Station is what was searched for
+	Station string `json:"station"`
+	// Source and Dest are platforms and services like the -source and -dest flags, e.g. way/123,456,way/789
+	// with the platform edge that was chosen, so it doesn't have to be chosen again
+	Source string `json:"source"`
+	Dest   string `json:"dest"`
+	// Label describes the transfer, e.g. U1 on platform 1 to U2
+	Label    string    `json:"label"`
+	Starred  bool      `json:"starred"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// a transfer is the same if it is between the same platforms and services, the edges might have been chosen differently
+func (t Transfer) key() string {
+	return platformAndService(t.Source) + ">" + platformAndService(t.Dest)
+}
+
+// cuts off the edge of a selection
+func platformAndService(selection string) string {
+	parts := strings.SplitN(selection, ",", 3)
+	return strings.Join(parts[:min(len(parts), 2)], ",")
+}
+
+// Store reads and writes the transfers in the preferences, it can be used from any goroutine
+type Store struct {
+	preferences fyne.Preferences
+	mutex       sync.Mutex
+	// OnChanged is called after the transfers changed
+	OnChanged func()
+}
+
+func NewStore(preferences fyne.Preferences) *Store {
+	return &Store{preferences: preferences}
+}
+
+// List returns the starred transfers and then the recent ones, the most recently used first
+func (s *Store) List() []Transfer {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.load()
+}
+
+// Add records a transfer that was just run. If it was run before, it keeps its star and the chosen edges are updated.
+// Only the MaxRecent most recently used transfers that aren't starred are kept.
+func (s *Store) Add(transfer Transfer) {
+	s.update(func(transfers []Transfer) []Transfer {
+		index := slices.IndexFunc(transfers, func(saved Transfer) bool {
+			return saved.key() == transfer.key()
+		})
+		if index != -1 {
+			transfer.Starred = transfers[index].Starred
+			transfers = slices.Delete(transfers, index, index+1)
+		}
+		transfers = append(transfers, transfer)
+		sortTransfers(transfers)
+
+		var recent int
+		return slices.DeleteFunc(transfers, func(saved Transfer) bool {
+			if saved.Starred {
+				return false
+			}
+			recent++
+			return recent > MaxRecent
+		})
+	})
+}
+
+// SetStarred stars or unstars the transfer, an unstarred one is kept as recent transfer
+func (s *Store) SetStarred(transfer Transfer, starred bool) {
+	s.update(func(transfers []Transfer) []Transfer {
+		for i := range transfers {
+			if transfers[i].key() == transfer.key() {
+				transfers[i].Starred = starred
+			}
+		}
+		sortTransfers(transfers)
+		return transfers
+	})
+}
+
+// Remove forgets the transfer
+func (s *Store) Remove(transfer Transfer) {
+	s.update(func(transfers []Transfer) []Transfer {
+		return slices.DeleteFunc(transfers, func(saved Transfer) bool {
+			return saved.key() == transfer.key()
+		})
+	})
+}
+
+func (s *Store) update(change func([]Transfer) []Transfer) {
+	s.mutex.Lock()
+	transfers := change(s.load())
+	data, err := json.Marshal(transfers)
+	if err != nil {
+		s.mutex.Unlock()
+		log.Err(err).Msg("failed encoding the saved transfers")
+		return
+	}
+	s.preferences.SetString(preferencesKey, string(data))
+	s.mutex.Unlock()
+
+	if s.OnChanged != nil {
+		s.OnChanged()
+	}
+}
+
+// the mutex has to be held
+func (s *Store) load() []Transfer {
+	data := s.preferences.String(preferencesKey)
+	if data == "" {
+		return nil
+	}
+	var transfers []Transfer
+	// broken preferences shouldn't stop the app, they are overwritten with the next transfer
+	if err := json.Unmarshal([]byte(data), &transfers); err != nil {
+		log.Err(err).Msg("failed decoding the saved transfers")
+		return nil
+	}
+	sortTransfers(transfers)
+	return transfers
+}
+
+func sortTransfers(transfers []Transfer) {
+	slices.SortStableFunc(transfers, func(a, b Transfer) int {
+		if a.Starred != b.Starred {
+			if a.Starred {
+				return -1
+			}
+			return 1
+		}
+		return b.LastUsed.Compare(a.LastUsed)
+	})
+}
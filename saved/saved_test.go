@@ -0,0 +1,92 @@
+package saved
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/test"
+)
+
+func TestStore(t *testing.T) {
+	app := test.NewApp()
+	defer app.Quit()
+	store := NewStore(app.Preferences())
+	var changes int
+	store.OnChanged = func() {
+		changes++
+	}
+
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	transfer := func(source string, minutes int) Transfer {
+		return Transfer{Station: "Alexanderplatz", Source: source, Dest: "way/2,20", LastUsed: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+	for i := 0; i < MaxRecent+2; i++ {
+		store.Add(transfer(fmt.Sprintf("way/1,%d", i), i))
+	}
+	transfers := store.List()
+	if len(transfers) != MaxRecent {
+		t.Fatalf("expected %d recent transfers, got %d", MaxRecent, len(transfers))
+	}
+	if transfers[0].Source != fmt.Sprintf("way/1,%d", MaxRecent+1) {
+		t.Errorf("expected the most recent transfer first, got %v", transfers[0])
+	}
+
+	// starred transfers come first and aren't pushed out by recent ones
+	oldest := transfers[len(transfers)-1]
+	store.SetStarred(oldest, true)
+	for i := 0; i < MaxRecent; i++ {
+		store.Add(transfer(fmt.Sprintf("way/3,%d", i), 100+i))
+	}
+	transfers = store.List()
+	if len(transfers) != MaxRecent+1 || !transfers[0].Starred || transfers[0].Source != oldest.Source {
+		t.Errorf("expected the starred transfer to be kept first, got %v", transfers)
+	}
+
+	// running it again with another edge keeps the star and updates the edge
+	again := oldest
+	again.Source += ",way/99"
+	again.LastUsed = start.Add(time.Hour * 24)
+	store.Add(again)
+	transfers = store.List()
+	if len(transfers) != MaxRecent+1 || !transfers[0].Starred || transfers[0].Source != again.Source {
+		t.Errorf("expected the starred transfer to be updated, got %v", transfers[0])
+	}
+
+	store.Remove(again)
+	if transfers := store.List(); len(transfers) != MaxRecent || transfers[0].Starred {
+		t.Errorf("expected the starred transfer to be removed, got %v", transfers)
+	}
+	if changes != MaxRecent+2+1+MaxRecent+1+1 {
+		t.Errorf("expected a change for every call, got %d", changes)
+	}
+}
+
+func TestStoreBrokenPreferences(t *testing.T) {
+	app := test.NewApp()
+	defer app.Quit()
+	app.Preferences().SetString(preferencesKey, "{broken")
+	store := NewStore(app.Preferences())
+	if transfers := store.List(); len(transfers) != 0 {
+		t.Errorf("expected no transfers, got %v", transfers)
+	}
+	store.Add(Transfer{Source: "way/1,10", Dest: "way/2,20"})
+	if transfers := store.List(); len(transfers) != 1 {
+		t.Errorf("expected the broken preferences to be replaced, got %v", transfers)
+	}
+}
+
+func TestStoreLastDataset(t *testing.T) {
+	app := test.NewApp()
+	defer app.Quit()
+	store := NewStore(app.Preferences())
+	if uri := store.LastDataset(); uri != nil {
+		t.Errorf("expected no last dataset, got %v", uri)
+	}
+	store.SetLastDataset(storage.NewFileURI("/data/berlin-latest.osm.pbf"))
+	uri := store.LastDataset()
+	if uri == nil || uri.Path() != "/data/berlin-latest.osm.pbf" || uri.Name() != "berlin-latest.osm.pbf" {
+		t.Errorf("expected the last dataset to be remembered, got %v", uri)
+	}
+}
@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"sync"
+
+	fyne "fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jkulzer/platform-router/saved"
+)
+
+// SavedTransfersWidget lists the starred and recent transfers, they can be run again once data is loaded
+type SavedTransfersWidget struct {
+	widget.BaseWidget
+	store   *saved.Store
+	content *fyne.Container
+	mutex   sync.Mutex
+	onRun   func(saved.Transfer)
+}
+
+func NewSavedTransfersWidget(store *saved.Store) *SavedTransfersWidget {
+	w := &SavedTransfersWidget{store: store, content: container.NewVBox()}
+	w.ExtendBaseWidget(w)
+	store.OnChanged = w.Refresh
+	return w
+}
+
+// SetOnRun enables the run buttons, they run the transfer with the function
+func (w *SavedTransfersWidget) SetOnRun(onRun func(saved.Transfer)) {
+	w.mutex.Lock()
+	w.onRun = onRun
+	w.mutex.Unlock()
+	w.Refresh()
+}
+
+func (w *SavedTransfersWidget) CreateRenderer() fyne.WidgetRenderer {
+	w.update()
+	return widget.NewSimpleRenderer(w.content)
+}
+
+func (w *SavedTransfersWidget) Refresh() {
+	w.update()
+	w.BaseWidget.Refresh()
+}
+
+// rebuilds the rows from the store
+func (w *SavedTransfersWidget) update() {
+	w.mutex.Lock()
+	onRun := w.onRun
+	w.mutex.Unlock()
+
+	var rows []fyne.CanvasObject
+	var starredHeader, recentHeader bool
+	for _, transfer := range w.store.List() {
+		if transfer.Starred && !starredHeader {
+			rows = append(rows, widget.NewLabelWithStyle("Starred transfers", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+			starredHeader = true
+		}
+		if !transfer.Starred && !recentHeader {
+			rows = append(rows, widget.NewLabelWithStyle("Recent transfers", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+			recentHeader = true
+		}
+		rows = append(rows, w.row(transfer, onRun))
+	}
+	w.content.Objects = rows
+	w.content.Refresh()
+}
+
+func (w *SavedTransfersWidget) row(transfer saved.Transfer, onRun func(saved.Transfer)) fyne.CanvasObject {
+	runButton := widget.NewButtonWithIcon("Run", theme.MediaPlayIcon(), func() {
+		onRun(transfer)
+	})
+	// the data has to be loaded first
+	if onRun == nil {
+		runButton.Disable()
+	}
+	starText := "Star"
+	if transfer.Starred {
+		starText = "Unstar"
+	}
+	starButton := widget.NewButton(starText, func() {
+		go w.store.SetStarred(transfer, !transfer.Starred)
+	})
+	removeButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		go w.store.Remove(transfer)
+	})
+
+	label := widget.NewLabel(transfer.Station + ": " + transfer.Label)
+	label.Truncation = fyne.TextTruncateEllipsis
+	return container.NewBorder(nil, nil, nil, container.NewHBox(runButton, starButton, removeButton), label)
+}
@@ -0,0 +1,175 @@
+package ui
+
+import (
+	"strings"
+	"sync"
+
+	fyne "fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jkulzer/platform-router/ingest"
+)
+
+// how many stations the dropdown shows at most
+const stationSuggestions = 8
+
+// StationEntry is the search entry. Once the stations of the data are set, it shows the matching ones with their lines while typing.
+type StationEntry struct {
+	widget.Entry
+	// OnSelected is called with the station picked from the dropdown
+	OnSelected func(ingest.Station)
+
+	mutex    sync.Mutex
+	stations []ingest.Station
+	matches  []ingest.Station
+	// the match that is picked with enter, moved with the arrow keys
+	highlighted int
+	// set while the text is replaced by the picked station, so the dropdown doesn't open again
+	picking bool
+	list    *stationList
+	popUp   *widget.PopUp
+}
+
+// stationList is the dropdown of the entry. While it is open it has the focus, since the entry isn't part of the overlay,
+// so it passes the typed keys on to the entry.
+type stationList struct {
+	widget.List
+	entry *StationEntry
+}
+
+func (l *stationList) TypedRune(r rune) {
+	l.entry.TypedRune(r)
+}
+
+func (l *stationList) TypedKey(key *fyne.KeyEvent) {
+	l.entry.TypedKey(key)
+}
+
+func (l *stationList) TypedShortcut(shortcut fyne.Shortcut) {
+	l.entry.TypedShortcut(shortcut)
+}
+
+func NewStationEntry() *StationEntry {
+	w := &StationEntry{}
+	w.ExtendBaseWidget(w)
+	w.list = &stationList{entry: w}
+	w.list.Length = func() int {
+		return len(w.matches)
+	}
+	w.list.CreateItem = func() fyne.CanvasObject {
+		text := widget.NewRichText(&widget.TextSegment{Style: widget.RichTextStyleStrong}, &widget.TextSegment{Style: widget.RichTextStyleInline})
+		text.Truncation = fyne.TextTruncateEllipsis
+		return text
+	}
+	w.list.UpdateItem = func(id widget.ListItemID, object fyne.CanvasObject) {
+		if id >= len(w.matches) {
+			return
+		}
+		text := object.(*widget.RichText)
+		name := text.Segments[0].(*widget.TextSegment)
+		name.Text = w.matches[id].Name
+		name.Style = widget.RichTextStyleStrong
+		if id == w.highlighted {
+			name.Style.ColorName = theme.ColorNamePrimary
+		}
+		text.Segments[1].(*widget.TextSegment).Text = "  " + strings.Join(w.matches[id].Lines, ", ")
+		text.Refresh()
+	}
+	w.list.ExtendBaseWidget(w.list)
+	w.list.OnSelected = func(id widget.ListItemID) {
+		w.list.UnselectAll()
+		w.pick(id)
+	}
+	w.OnChanged = w.suggest
+	return w
+}
+
+// SetStations sets the stations that are suggested, it can be called from any goroutine
+func (w *StationEntry) SetStations(stations []ingest.Station) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.stations = stations
+}
+
+// TypedKey moves through the suggestions with the arrow keys, picks one with enter and closes them with escape
+func (w *StationEntry) TypedKey(key *fyne.KeyEvent) {
+	if w.popUp == nil || !w.popUp.Visible() {
+		w.Entry.TypedKey(key)
+		return
+	}
+	switch key.Name {
+	case fyne.KeyDown:
+		w.highlighted = min(w.highlighted+1, len(w.matches)-1)
+		w.list.ScrollTo(w.highlighted)
+		w.list.Refresh()
+	case fyne.KeyUp:
+		w.highlighted = max(w.highlighted-1, 0)
+		w.list.ScrollTo(w.highlighted)
+		w.list.Refresh()
+	case fyne.KeyReturn, fyne.KeyEnter:
+		w.pick(w.highlighted)
+	case fyne.KeyEscape:
+		w.hideSuggestions()
+	default:
+		w.Entry.TypedKey(key)
+	}
+}
+
+func (w *StationEntry) suggest(text string) {
+	if w.picking {
+		return
+	}
+	w.mutex.Lock()
+	w.matches = ingest.MatchStations(w.stations, text, stationSuggestions)
+	w.mutex.Unlock()
+	w.highlighted = 0
+	if len(w.matches) == 0 {
+		w.hideSuggestions()
+		return
+	}
+	w.list.Refresh()
+	w.list.ScrollToTop()
+
+	canvas := fyne.CurrentApp().Driver().CanvasForObject(w)
+	if canvas == nil {
+		return
+	}
+	if w.popUp == nil {
+		w.popUp = widget.NewPopUp(w.list, canvas)
+	}
+	// the list has no minimum height of its own, so the dropdown is made as high as its rows
+	rowHeight := w.list.CreateItem().MinSize().Height + theme.SeparatorThicknessSize() + theme.Padding()
+	w.popUp.Resize(fyne.NewSize(w.Size().Width, float32(len(w.matches))*rowHeight+theme.Padding()))
+	position := fyne.CurrentApp().Driver().AbsolutePositionForObject(w)
+	w.popUp.ShowAtPosition(position.AddXY(0, w.Size().Height))
+	canvas.Focus(w.list)
+}
+
+// closes the dropdown and gives the focus back to the entry
+func (w *StationEntry) hideSuggestions() {
+	if w.popUp == nil || !w.popUp.Visible() {
+		return
+	}
+	w.popUp.Hide()
+	if canvas := fyne.CurrentApp().Driver().CanvasForObject(w); canvas != nil {
+		canvas.Focus(w)
+	}
+}
+
+// replaces the text with the name of the picked station and passes it on
+func (w *StationEntry) pick(id widget.ListItemID) {
+	if id < 0 || id >= len(w.matches) {
+		return
+	}
+	station := w.matches[id]
+	w.hideSuggestions()
+	w.picking = true
+	w.SetText(station.Name)
+	w.CursorColumn = len([]rune(station.Name))
+	w.Refresh()
+	w.picking = false
+	if w.OnSelected != nil {
+		w.OnSelected(station)
+	}
+}
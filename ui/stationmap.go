@@ -0,0 +1,221 @@
+package ui
+
+import (
+	"image/color"
+	"math"
+
+	fyne "fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jkulzer/platform-router/helpers"
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/clip"
+)
+
+// how much one step of the mouse wheel or a zoom button zooms
+const zoomStep = 1.25
+
+var (
+	mapBackgroundColor = color.RGBA{30, 30, 30, 255}
+	// used for the path and doors if a service has no valid colour
+	defaultServiceColor = color.RGBA{0, 120, 255, 255}
+)
+
+// the style of each kind of way, the later kinds are drawn on top
+var mapFeatureStyles = map[models.MapFeatureKind]struct {
+	color color.Color
+	width float32
+}{
+	models.MapFeaturePlatform:  {color.RGBA{140, 140, 170, 255}, 2},
+	models.MapFeatureTrack:     {color.RGBA{210, 210, 210, 255}, 2},
+	models.MapFeatureFootway:   {color.RGBA{170, 130, 90, 255}, 1},
+	models.MapFeatureSteps:     {color.RGBA{240, 130, 40, 255}, 2},
+	models.MapFeatureEscalator: {color.RGBA{210, 80, 210, 255}, 2},
+}
+
+// StationMapWidget draws the ways around a transfer from the loaded data, without a tile server.
+// It is panned by dragging and zoomed with the mouse wheel or ZoomIn and ZoomOut.
+type StationMapWidget struct {
+	widget.BaseWidget
+	features []models.MapFeature
+	transfer models.Transfer
+
+	// positions on the map are in meters east and north of the middle between the doors
+	projection func(point orb.Point) orb.Point
+	// center is the position shown in the middle of the widget
+	center orb.Point
+	// zoom is in pixels per meter, 0 until the map was fitted to the transfer
+	zoom float64
+}
+
+func NewStationMapWidget(transfer models.Transfer, features []models.MapFeature) *StationMapWidget {
+	origin := orb.MultiPoint{transfer.Source.Door, transfer.Dest.Door}.Bound().Center()
+	w := &StationMapWidget{
+		features:   features,
+		transfer:   transfer,
+		projection: helpers.LocalProjection(origin),
+	}
+	w.ExtendBaseWidget(w)
+	return w
+}
+
+func (w *StationMapWidget) CreateRenderer() fyne.WidgetRenderer {
+	return &stationMapRenderer{widget: w, background: canvas.NewRectangle(mapBackgroundColor)}
+}
+
+// project converts a WGS84 point to meters east and north of the middle between the doors
+func (w *StationMapWidget) project(point orb.Point) orb.Point {
+	return w.projection(point)
+}
+
+// toScreen converts a projected point to pixels within the widget
+func (w *StationMapWidget) toScreen(point orb.Point, size fyne.Size) orb.Point {
+	return orb.Point{
+		float64(size.Width)/2 + (point.X()-w.center.X())*w.zoom,
+		float64(size.Height)/2 - (point.Y()-w.center.Y())*w.zoom,
+	}
+}
+
+// fit shows the whole path and both platforms
+func (w *StationMapWidget) fit(size fyne.Size) {
+	points := orb.MultiPoint{
+		w.project(w.transfer.Source.Spine.Start), w.project(w.transfer.Source.Spine.End),
+		w.project(w.transfer.Dest.Spine.Start), w.project(w.transfer.Dest.Spine.End),
+	}
+	for _, node := range w.transfer.Path {
+		points = append(points, w.project(node.Point))
+	}
+	bound := points.Bound()
+	w.center = bound.Center()
+	// at least 50 meters are shown, so a transfer across one platform isn't zoomed in too far
+	width := math.Max(bound.Max.X()-bound.Min.X(), 50)
+	height := math.Max(bound.Max.Y()-bound.Min.Y(), 50)
+	w.zoom = 0.9 * math.Min(float64(size.Width)/width, float64(size.Height)/height)
+}
+
+func (w *StationMapWidget) Dragged(event *fyne.DragEvent) {
+	if w.zoom == 0 {
+		return
+	}
+	w.center[0] -= float64(event.Dragged.DX) / w.zoom
+	w.center[1] += float64(event.Dragged.DY) / w.zoom
+	w.Refresh()
+}
+
+func (w *StationMapWidget) DragEnd() {}
+
+// Scrolled zooms while keeping the point under the cursor in place
+func (w *StationMapWidget) Scrolled(event *fyne.ScrollEvent) {
+	if w.zoom == 0 || event.Scrolled.DY == 0 {
+		return
+	}
+	factor := zoomStep
+	if event.Scrolled.DY < 0 {
+		factor = 1 / zoomStep
+	}
+	size := w.Size()
+	offsetX := float64(event.Position.X - size.Width/2)
+	offsetY := float64(size.Height/2 - event.Position.Y)
+	cursorX := w.center.X() + offsetX/w.zoom
+	cursorY := w.center.Y() + offsetY/w.zoom
+	w.zoom *= factor
+	w.center = orb.Point{cursorX - offsetX/w.zoom, cursorY - offsetY/w.zoom}
+	w.Refresh()
+}
+
+func (w *StationMapWidget) ZoomIn() {
+	w.zoom *= zoomStep
+	w.Refresh()
+}
+
+func (w *StationMapWidget) ZoomOut() {
+	w.zoom /= zoomStep
+	w.Refresh()
+}
+
+type stationMapRenderer struct {
+	widget     *StationMapWidget
+	background *canvas.Rectangle
+	objects    []fyne.CanvasObject
+}
+
+func (r *stationMapRenderer) Layout(size fyne.Size) {
+	r.background.Resize(size)
+	if r.widget.zoom == 0 && size.Width > 0 && size.Height > 0 {
+		r.widget.fit(size)
+	}
+	r.draw(size)
+}
+
+func (r *stationMapRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(300, 300)
+}
+
+func (r *stationMapRenderer) Refresh() {
+	r.draw(r.widget.Size())
+	canvas.Refresh(r.widget)
+}
+
+func (r *stationMapRenderer) Objects() []fyne.CanvasObject {
+	return append([]fyne.CanvasObject{r.background}, r.objects...)
+}
+
+func (r *stationMapRenderer) Destroy() {}
+
+// draw creates the objects for the current position and zoom, only the parts within the widget are drawn
+func (r *stationMapRenderer) draw(size fyne.Size) {
+	r.objects = r.objects[:0]
+	if r.widget.zoom == 0 {
+		return
+	}
+	screen := orb.Bound{Max: orb.Point{float64(size.Width), float64(size.Height)}}
+
+	addLine := func(line orb.LineString, lineColor color.Color, width float32) {
+		projected := make(orb.LineString, len(line))
+		for i, point := range line {
+			projected[i] = r.widget.toScreen(r.widget.project(point), size)
+		}
+		for _, part := range clip.LineString(screen, projected) {
+			for i := 0; i+1 < len(part); i++ {
+				segment := canvas.NewLine(lineColor)
+				segment.StrokeWidth = width
+				segment.Position1 = fyne.NewPos(float32(part[i].X()), float32(part[i].Y()))
+				segment.Position2 = fyne.NewPos(float32(part[i+1].X()), float32(part[i+1].Y()))
+				r.objects = append(r.objects, segment)
+			}
+		}
+	}
+
+	for _, kind := range []models.MapFeatureKind{models.MapFeaturePlatform, models.MapFeatureTrack, models.MapFeatureFootway, models.MapFeatureSteps, models.MapFeatureEscalator} {
+		style := mapFeatureStyles[kind]
+		for _, feature := range r.widget.features {
+			if feature.Kind == kind {
+				addLine(feature.Line, style.color, style.width)
+			}
+		}
+	}
+
+	transfer := r.widget.transfer
+	path := make(orb.LineString, len(transfer.Path))
+	for i, node := range transfer.Path {
+		path[i] = node.Point
+	}
+	addLine(path, helpers.ServiceColor(transfer.Source.ServiceColour, defaultServiceColor), 5)
+
+	for _, end := range []models.TransferEnd{transfer.Source, transfer.Dest} {
+		door := r.widget.toScreen(r.widget.project(end.Door), size)
+		if !screen.Contains(door) {
+			continue
+		}
+		const radius = 7
+		marker := canvas.NewCircle(helpers.ServiceColor(end.ServiceColour, defaultServiceColor))
+		marker.StrokeColor = color.White
+		marker.StrokeWidth = 2
+		marker.Move(fyne.NewPos(float32(door.X())-radius, float32(door.Y())-radius))
+		marker.Resize(fyne.NewSize(2*radius, 2*radius))
+		r.objects = append(r.objects, marker)
+	}
+}
@@ -1,20 +1,35 @@
 package ui
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"image/color"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	fyne "fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
+	"github.com/jkulzer/platform-router/export"
 	"github.com/jkulzer/platform-router/helpers"
+	"github.com/jkulzer/platform-router/ingest"
 	"github.com/jkulzer/platform-router/models"
+	"github.com/jkulzer/platform-router/output"
 
 	"github.com/jkulzer/osm"
+	"github.com/paulmach/orb"
 
 	"github.com/rs/zerolog/log"
 )
@@ -28,6 +43,8 @@ type PlatformSelectorWidget struct {
 	destService        osm.Relation
 	SourcePlatformChan chan models.PlatformAndServiceSelection
 	DestPlatformChan   chan models.PlatformAndServiceSelection
+	// Done stops sending selections once nobody receives them anymore, e.g. because the search was cancelled
+	Done <-chan struct{}
 }
 
 func NewPlatformSelector(items models.PlatformList) *PlatformSelectorWidget {
@@ -36,102 +53,178 @@ func NewPlatformSelector(items models.PlatformList) *PlatformSelectorWidget {
 	return w
 }
 
+// serviceModes are the groups of the service list in the order they are shown, services of other route types are listed last
+var serviceModes = []struct {
+	route string
+	name  string
+}{
+	{"train", "Train"},
+	{"railway", "Railway"},
+	{"light_rail", "Light rail"},
+	{"subway", "Subway"},
+	{"monorail", "Monorail"},
+	{"funicular", "Funicular"},
+	{"tram", "Tram"},
+	{"trolleybus", "Trolley Bus"},
+	{"bus", "Bus"},
+	{"ferry", "Ferry"},
+}
+
+// the group of the services whose route type isn't one of the serviceModes
+const otherServicesName = "Everything else"
+
+// a service at one of the platforms, it is one row of the list
+type serviceEntry struct {
+	service        *osm.Relation
+	platformID     osm.ElementID
+	platformNumber string
+	// modeIndex is the index in serviceModes, len(serviceModes) for everything else
+	modeIndex int
+	mode      string
+}
+
 func (w *PlatformSelectorWidget) CreateRenderer() fyne.WidgetRenderer {
-	content := container.NewVBox()
-	content.Add(container.NewVBox(canvas.NewText("Service list:", color.White)))
+	entries := w.serviceEntries()
+	list := container.NewVBox()
 
-	serviceToPlatform := make(map[*osm.Relation]osm.ElementID)
+	// only the modes chosen with the chips are shown, all of them if none is chosen
+	chosenModes := make(map[string]bool)
+	filterEntry := widget.NewEntry()
+	filterEntry.SetPlaceHolder("Filter by line, destination or platform")
+	update := func() {
+		w.showServices(list, entries, filterEntry.Text, chosenModes)
+	}
+	filterEntry.OnChanged = func(string) {
+		update()
+	}
 
+	chips := container.NewHBox()
+	for _, mode := range entryModes(entries) {
+		chip := widget.NewButton(mode, nil)
+		chip.OnTapped = func() {
+			if chosenModes[mode] {
+				delete(chosenModes, mode)
+				chip.Importance = widget.MediumImportance
+			} else {
+				chosenModes[mode] = true
+				chip.Importance = widget.HighImportance
+			}
+			chip.Refresh()
+			update()
+		}
+		chips.Add(chip)
+	}
+	update()
+
+	header := container.NewVBox(canvas.NewText("Service list:", color.White), filterEntry)
+	// choosing a mode only makes sense if there is more than one
+	if len(chips.Objects) > 1 {
+		header.Add(container.NewHScroll(chips))
+	}
+	scroll := container.NewVScroll(list)
+	return widget.NewSimpleRenderer(container.NewBorder(header, nil, nil, nil, scroll))
+}
+
+// lists every service at every platform, sorted by mode, line, destination and platform, so the order is the same on every run
+func (w *PlatformSelectorWidget) serviceEntries() []serviceEntry {
+	var entries []serviceEntry
 	for _, platform := range w.items.Platforms {
+		platformNumber := w.platformNumber(platform.ElementID)
 		for _, service := range platform.Services {
-			serviceToPlatform[service] = platform.ElementID
+			modeIndex, mode := serviceMode(service)
+			entries = append(entries, serviceEntry{
+				service:        service,
+				platformID:     platform.ElementID,
+				platformNumber: platformNumber,
+				modeIndex:      modeIndex,
+				mode:           mode,
+			})
 		}
 	}
+	slices.SortFunc(entries, func(a, b serviceEntry) int {
+		return cmp.Or(
+			cmp.Compare(a.modeIndex, b.modeIndex),
+			compareRefs(a.service.Tags.Find("ref"), b.service.Tags.Find("ref")),
+			helpers.CompareNatural(a.service.Tags.Find("to"), b.service.Tags.Find("to")),
+			helpers.CompareNatural(a.platformNumber, b.platformNumber),
+			cmp.Compare(a.service.ID, b.service.ID),
+			cmp.Compare(a.platformID, b.platformID),
+		)
+	})
+	return entries
+}
 
-	lightRailServices := make(map[*osm.Relation]osm.ElementID)
-	subwayServices := make(map[*osm.Relation]osm.ElementID)
-	tramServices := make(map[*osm.Relation]osm.ElementID)
-	trolleyBusServices := make(map[*osm.Relation]osm.ElementID)
-	busServices := make(map[*osm.Relation]osm.ElementID)
-	ferryServices := make(map[*osm.Relation]osm.ElementID)
-	remainingServices := make(map[*osm.Relation]osm.ElementID)
-
-	for service, platform := range serviceToPlatform {
-		switch service.Tags.Find("route") {
-		case "light_rail":
-			lightRailServices[service] = platform
-		case "subway":
-			subwayServices[service] = platform
-		case "tram":
-			tramServices[service] = platform
-		case "trolleybus":
-			trolleyBusServices[service] = platform
-		case "bus":
-			busServices[service] = platform
-		case "ferry":
-			ferryServices[service] = platform
-		default:
-			remainingServices[service] = platform
+// returns the group of the service in the list
+func serviceMode(service *osm.Relation) (int, string) {
+	route := service.Tags.Find("route")
+	for i, mode := range serviceModes {
+		if mode.route == route {
+			return i, mode.name
 		}
 	}
+	return len(serviceModes), otherServicesName
+}
 
-	if len(lightRailServices) != 0 {
-		serviceHeadline := canvas.NewText("Light rail:", color.White)
-		content.Add(serviceHeadline)
-		for service, platformID := range lightRailServices {
-			displayService(w, service, platformID, content)
+// the modes of the sorted entries in the order they are listed
+func entryModes(entries []serviceEntry) []string {
+	var modes []string
+	for _, entry := range entries {
+		if !slices.Contains(modes, entry.mode) {
+			modes = append(modes, entry.mode)
 		}
 	}
-	if len(subwayServices) != 0 {
-		serviceHeadline := canvas.NewText("Subway:", color.White)
-		content.Add(serviceHeadline)
-		for service, platformID := range subwayServices {
-			displayService(w, service, platformID, content)
-		}
+	return modes
+}
+
+// compares refs naturally, services without a ref come last
+func compareRefs(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
 	}
-	if len(tramServices) != 0 {
-		serviceHeadline := canvas.NewText("Tram:", color.White)
-		content.Add(serviceHeadline)
-		for service, platformID := range tramServices {
-			displayService(w, service, platformID, content)
-		}
+	return helpers.CompareNatural(a, b)
+}
+
+// reports if the filter is part of the line, destination, name or platform number of the service, ignoring case
+func (e serviceEntry) matches(filter string) bool {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	if filter == "" {
+		return true
 	}
-	if len(trolleyBusServices) != 0 {
-		serviceHeadline := canvas.NewText("Trolley Bus:", color.White)
-		content.Add(serviceHeadline)
-		for service, platformID := range trolleyBusServices {
-			displayService(w, service, platformID, content)
+	for _, text := range []string{e.service.Tags.Find("ref"), e.service.Tags.Find("to"), e.service.Tags.Find("name"), e.platformNumber} {
+		if strings.Contains(strings.ToLower(text), filter) {
+			return true
 		}
 	}
-	if len(busServices) != 0 {
-		serviceHeadline := canvas.NewText("Bus:", color.White)
-		content.Add(serviceHeadline)
-		for service, platformID := range busServices {
-			displayService(w, service, platformID, content)
+	return false
+}
+
+// shows the entries that match the filter and the chosen modes with a headline for each mode
+func (w *PlatformSelectorWidget) showServices(list *fyne.Container, entries []serviceEntry, filter string, chosenModes map[string]bool) {
+	list.RemoveAll()
+	var lastMode string
+	for _, entry := range entries {
+		if !entry.matches(filter) || (len(chosenModes) != 0 && !chosenModes[entry.mode]) {
+			continue
 		}
-	}
-	if len(ferryServices) != 0 {
-		serviceHeadline := canvas.NewText("Ferry:", color.White)
-		content.Add(serviceHeadline)
-		for service, platformID := range ferryServices {
-			displayService(w, service, platformID, content)
+		if entry.mode != lastMode {
+			list.Add(canvas.NewText(entry.mode+":", color.White))
+			lastMode = entry.mode
 		}
+		displayService(w, entry, list)
 	}
-	if len(remainingServices) != 0 {
-		serviceHeadline := canvas.NewText("Everything else:", color.White)
-		content.Add(serviceHeadline)
-		for service, platformID := range remainingServices {
-			displayService(w, service, platformID, content)
-		}
+	if len(list.Objects) == 0 {
+		list.Add(widget.NewLabel("No service matches the filter"))
 	}
-
-	scroll := container.NewVScroll(content)
-	return widget.NewSimpleRenderer(scroll)
+	list.Refresh()
 }
 
-func displayService(w *PlatformSelectorWidget, service *osm.Relation, platformID osm.ElementID, content *fyne.Container) {
-	// platform details
-	var platformNumber string
+// returns the ref of the platform, empty if it has none
+func (w *PlatformSelectorWidget) platformNumber(platformID osm.ElementID) string {
 	platformType, err := platformID.Type()
 	if err != nil {
 		log.Err(err).Msg("invalid element type detected")
@@ -142,18 +235,46 @@ func displayService(w *PlatformSelectorWidget, service *osm.Relation, platformID
 		if err != nil {
 			log.Err(err).Msg("determining WayID of platform " + fmt.Sprint(platformID) + " failed since it is not of type way")
 		}
-		platformData := w.items.Ways[wayID]
-		platformNumber = platformData.Tags.Find("ref")
+		return w.items.Ways[wayID].Tags.Find("ref")
 	case "relation":
 		relationID, err := platformID.RelationID()
 		if err != nil {
 			log.Err(err).Msg("determining RelationID of platform " + fmt.Sprint(platformID) + " failed since it is not of type relation")
 		}
-		platformData := w.items.Relations[relationID]
-		platformNumber = platformData.Tags.Find("ref")
+		return w.items.Relations[relationID].Tags.Find("ref")
 	default:
 		log.Warn().Msg("Platform " + fmt.Sprint(platformID) + " is neither way nor relation")
 	}
+	return ""
+}
+
+// sends the selected platforms without blocking the UI
+func (w *PlatformSelectorWidget) sendSelection() {
+	source := models.PlatformAndServiceSelection{
+		Platform: w.sourcePlatform,
+		Service:  w.sourceService.ID,
+	}
+	dest := models.PlatformAndServiceSelection{
+		Platform: w.destPlatform,
+		Service:  w.destService.ID,
+	}
+	go func() {
+		select {
+		case w.SourcePlatformChan <- source:
+		case <-w.Done:
+			return
+		}
+		select {
+		case w.DestPlatformChan <- dest:
+		case <-w.Done:
+		}
+	}()
+}
+
+func displayService(w *PlatformSelectorWidget, entry serviceEntry, content *fyne.Container) {
+	service := entry.service
+	platformID := entry.platformID
+	platformNumber := entry.platformNumber
 
 	// service details
 	serviceColor := color.RGBA{255, 255, 255, 1}
@@ -177,15 +298,7 @@ func displayService(w *PlatformSelectorWidget, service *osm.Relation, platformID
 		w.sourcePlatform = platformID
 		w.sourceService = *service
 		if int64(w.destPlatform) != 0 {
-			fmt.Println("done")
-			w.SourcePlatformChan <- models.PlatformAndServiceSelection{
-				Platform: w.sourcePlatform,
-				Service:  w.sourceService.ID,
-			}
-			w.DestPlatformChan <- models.PlatformAndServiceSelection{
-				Platform: w.destPlatform,
-				Service:  w.destService.ID,
-			}
+			w.sendSelection()
 		}
 	})
 	destButton := widget.NewButton("End here", func() {
@@ -193,15 +306,7 @@ func displayService(w *PlatformSelectorWidget, service *osm.Relation, platformID
 		w.destPlatform = platformID
 		w.destService = *service
 		if int64(w.sourcePlatform) != 0 {
-			fmt.Println("done")
-			w.SourcePlatformChan <- models.PlatformAndServiceSelection{
-				Platform: w.sourcePlatform,
-				Service:  w.sourceService.ID,
-			}
-			w.DestPlatformChan <- models.PlatformAndServiceSelection{
-				Platform: w.destPlatform,
-				Service:  w.destService.ID,
-			}
+			w.sendSelection()
 		}
 	})
 
@@ -216,14 +321,126 @@ func displayService(w *PlatformSelectorWidget, service *osm.Relation, platformID
 	content.Add(serviceContainer)
 }
 
-func DisplayResults(ctx models.AppContext, alongSourcePlatform float64, alongDestPlatform float64, fromPlatformStart float64, toPlatformStart float64) {
+func DisplayResults(ctx models.AppContext, transfer models.Transfer, mapFeatures []models.MapFeature, artifacts []output.Artifact) {
 
-	sourcePlatformText := canvas.NewText(fmt.Sprint(alongSourcePlatform*100)+"% along source platform or "+fmt.Sprint(fromPlatformStart)+"m", color.White)
-	destPlatformText := canvas.NewText(fmt.Sprint(alongDestPlatform*100)+"% along dest platform or "+fmt.Sprint(toPlatformStart)+"m", color.White)
-	serviceContainer := container.New(layout.NewVBoxLayout(), sourcePlatformText, destPlatformText)
+	sourcePlatformText := canvas.NewText(fmt.Sprint(transfer.Source.DoorFraction()*100)+"% along source platform or "+fmt.Sprint(transfer.Source.DoorDistance)+"m", color.White)
+	destPlatformText := canvas.NewText(fmt.Sprint(transfer.Dest.DoorFraction()*100)+"% along dest platform or "+fmt.Sprint(transfer.Dest.DoorDistance)+"m", color.White)
+	sourceSideText := canvas.NewText(platformSideText("source", transfer.Source.Side), color.White)
+	destSideText := canvas.NewText(platformSideText("dest", transfer.Dest.Side), color.White)
+	serviceContainer := container.New(layout.NewVBoxLayout(), sourcePlatformText, sourceSideText, destPlatformText, destSideText)
+	// distance, time, level changes and the warnings
+	for _, line := range export.Summary(transfer) {
+		text := canvas.NewText(line, color.White)
+		if strings.HasPrefix(line, "Warning: ") {
+			text.Color = theme.Color(theme.ColorNameWarning)
+		}
+		serviceContainer.Add(text)
+	}
+	// the schematic is next to the percentages it shows
+	summary := container.NewHBox(serviceContainer)
+	schematicImage, err := export.SchematicImage(transfer, 1)
+	if err != nil {
+		log.Err(err).Msg("failed drawing the platform schematic")
+	} else {
+		schematic := canvas.NewImageFromImage(schematicImage)
+		schematic.FillMode = canvas.ImageFillContain
+		schematic.SetMinSize(fyne.NewSize(float32(schematicImage.Bounds().Dx()), float32(schematicImage.Bounds().Dy())))
+		summary.Add(schematic)
+	}
 	content := container.NewVBox()
-	content.Add(serviceContainer)
-	ctx.Tabs.Items[2].Content = content
+	content.Add(summary)
+
+	exportButtons := container.NewHBox()
+	for _, format := range export.Formats {
+		exportButtons.Add(widget.NewButton("Export "+format.Name, func() {
+			showExportDialog(ctx.Window, format, transfer)
+		}))
+	}
+	content.Add(exportButtons)
+
+	// the files written to the output directory, if one is configured
+	for _, artifact := range artifacts {
+		content.Add(container.NewHBox(
+			widget.NewLabel(artifact.Kind+": "+artifact.Path),
+			widget.NewButton("Open", func() {
+				openArtifact(ctx.Window, artifact)
+			}),
+		))
+	}
+	stationMap := NewStationMapWidget(transfer, mapFeatures)
+	content.Add(container.NewHBox(
+		widget.NewButton("Zoom in", stationMap.ZoomIn),
+		widget.NewButton("Zoom out", stationMap.ZoomOut),
+	))
+	// the map takes up the space left below the text
+	ctx.Tabs.Items[2].Content = container.NewBorder(content, nil, nil, nil, stationMap)
+}
+
+// opens the artifact with the default application of the system
+func openArtifact(w fyne.Window, artifact output.Artifact) {
+	path, err := filepath.Abs(artifact.Path)
+	if err != nil {
+		dialog.ShowError(err, w)
+		return
+	}
+	fileURL := &url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	if err := fyne.CurrentApp().OpenURL(fileURL); err != nil {
+		log.Err(err).Msg("failed opening " + artifact.Path)
+		dialog.ShowError(err, w)
+	}
+}
+
+// asks where to save the transfer and writes it in the format
+func showExportDialog(w fyne.Window, format export.Format, transfer models.Transfer) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		// the dialog was cancelled
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+		if err := format.Write(writer, transfer); err != nil {
+			log.Err(err).Msg("failed exporting transfer as " + format.Name)
+			dialog.ShowError(err, w)
+			return
+		}
+		log.Info().Msg("exported transfer to " + writer.URI().String())
+	}, w)
+	saveDialog.SetFileName("transfer" + format.Extension)
+	saveDialog.Show()
+}
+
+// ShowReportDialog asks where to save the report of the station and writes it as HTML
+func ShowReportDialog(w fyne.Window, station string, entries []export.ReportEntry) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		// the dialog was cancelled
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+		if err := export.WriteReport(writer, station, entries, time.Now()); err != nil {
+			log.Err(err).Msg("failed writing the report of " + station)
+			dialog.ShowError(err, w)
+			return
+		}
+		log.Info().Msg("wrote station report to " + writer.URI().String())
+	}, w)
+	saveDialog.SetFileName("transfers.html")
+	saveDialog.Show()
+}
+
+func platformSideText(platformName string, side models.PlatformSide) string {
+	if side == models.PlatformSideUnknown {
+		return "unknown on which side the train arrives at the " + platformName + " platform"
+	}
+	return "train arrives with the " + platformName + " platform on its " + side.String() + " (doors open on the " + side.String() + ")"
 }
 
 func ShowFilePicker(w fyne.Window, reader chan (fyne.URIReadCloser), returnError chan (error)) {
@@ -282,3 +499,206 @@ func (w *LoadingScreenWithTextWidget) SetText(inputText string) {
 	}
 	w.Refresh()
 }
+
+// ClipOptionsWidget lets the user restrict loading to a bounding box or a GeoJSON polygon
+type ClipOptionsWidget struct {
+	widget.BaseWidget
+	window       fyne.Window
+	bboxEntry    *widget.Entry
+	bufferEntry  *widget.Entry
+	polygonLabel *widget.Label
+	polygon      orb.MultiPolygon
+}
+
+// NewClipOptionsWidget creates the widget, it is filled with the clip from the command line if there is one
+func NewClipOptionsWidget(window fyne.Window, initial *ingest.Clip) *ClipOptionsWidget {
+	w := &ClipOptionsWidget{
+		window:       window,
+		bboxEntry:    widget.NewEntry(),
+		bufferEntry:  widget.NewEntry(),
+		polygonLabel: widget.NewLabel("no polygon"),
+	}
+	w.bboxEntry.SetPlaceHolder("Only load bounding box: min lon, min lat, max lon, max lat")
+	w.bufferEntry.SetPlaceHolder("Buffer around the region in meters, default " + fmt.Sprint(ingest.DefaultClipBuffer))
+	if initial != nil {
+		switch region := initial.Region.(type) {
+		case orb.Bound:
+			w.bboxEntry.SetText(fmt.Sprint(region.Min.Lon()) + "," + fmt.Sprint(region.Min.Lat()) + "," + fmt.Sprint(region.Max.Lon()) + "," + fmt.Sprint(region.Max.Lat()))
+		case orb.MultiPolygon:
+			w.polygon = region
+			w.polygonLabel.SetText("polygon from command line")
+		}
+		w.bufferEntry.SetText(fmt.Sprint(initial.Buffer))
+	}
+	w.ExtendBaseWidget(w)
+	return w
+}
+
+func (w *ClipOptionsWidget) CreateRenderer() fyne.WidgetRenderer {
+	readerChan := make(chan fyne.URIReadCloser)
+	errChan := make(chan error)
+	polygonButton := widget.NewButton("Clip Polygon (GeoJSON)", func() {
+		go func() {
+			ShowFilePicker(w.window, readerChan, errChan)
+			reader := <-readerChan
+			err := <-errChan
+			if err != nil {
+				dialog.ShowError(err, w.window)
+				return
+			}
+			// the picker was cancelled
+			if reader == nil {
+				return
+			}
+			defer reader.Close()
+			polygon, err := ingest.ReadClipPolygon(reader)
+			if err != nil {
+				log.Err(err).Msg("failed reading clip polygon " + reader.URI().Name())
+				dialog.ShowError(err, w.window)
+				return
+			}
+			w.polygon = polygon
+			w.polygonLabel.SetText(reader.URI().Name())
+		}()
+	})
+	clearButton := widget.NewButton("Clear", func() {
+		w.polygon = nil
+		w.polygonLabel.SetText("no polygon")
+	})
+	content := container.NewVBox(
+		w.bboxEntry,
+		container.NewHBox(polygonButton, clearButton, w.polygonLabel),
+		w.bufferEntry,
+	)
+	return widget.NewSimpleRenderer(content)
+}
+
+// Clip returns the configured clip, nil if neither a bounding box nor a polygon is set
+func (w *ClipOptionsWidget) Clip() (*ingest.Clip, error) {
+	buffer := float64(ingest.DefaultClipBuffer)
+	if w.bufferEntry.Text != "" {
+		var err error
+		buffer, err = strconv.ParseFloat(w.bufferEntry.Text, 64)
+		if err != nil {
+			return nil, errors.New("invalid clip buffer " + w.bufferEntry.Text)
+		}
+	}
+	switch {
+	case w.bboxEntry.Text != "" && w.polygon != nil:
+		return nil, errors.New("clip to either a bounding box or a polygon, not both")
+	case w.bboxEntry.Text != "":
+		bbox, err := ingest.ParseBBox(w.bboxEntry.Text)
+		if err != nil {
+			return nil, err
+		}
+		return ingest.NewClip(bbox, buffer)
+	case w.polygon != nil:
+		return ingest.NewClip(w.polygon, buffer)
+	}
+	return nil, nil
+}
+
+// LoadingProgressWidget shows a progress bar for every phase of loading, the phases are added once they report progress
+type LoadingProgressWidget struct {
+	widget.BaseWidget
+	mutex   sync.Mutex
+	content *fyne.Container
+	bars    map[ingest.Phase]*widget.ProgressBar
+}
+
+func NewLoadingProgressWidget() *LoadingProgressWidget {
+	w := &LoadingProgressWidget{
+		content: container.NewVBox(),
+		bars:    make(map[ingest.Phase]*widget.ProgressBar),
+	}
+	w.ExtendBaseWidget(w)
+	return w
+}
+
+func (w *LoadingProgressWidget) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(w.content)
+}
+
+// Update shows the progress of a phase, it can be called from any goroutine
+func (w *LoadingProgressWidget) Update(progress ingest.Progress) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	bar, exists := w.bars[progress.Phase]
+	if !exists {
+		bar = widget.NewProgressBar()
+		w.bars[progress.Phase] = bar
+		w.content.Add(container.NewBorder(nil, nil, widget.NewLabel(string(progress.Phase)), nil, bar))
+	}
+	fraction := progress.Fraction()
+	if fraction < 0 {
+		// the size of the input is unknown, so only the amount read so far is shown
+		done := progress.Done
+		bar.TextFormatter = func() string {
+			return fmt.Sprint(done/(1<<20)) + " MB read"
+		}
+		bar.Refresh()
+		return
+	}
+	bar.TextFormatter = nil
+	bar.SetValue(fraction)
+}
+
+// Reset removes the bars of a previous load, e.g. after it was cancelled
+func (w *LoadingProgressWidget) Reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.bars = make(map[ingest.Phase]*widget.ProgressBar)
+	w.content.RemoveAll()
+}
+
+// CancelButton cancels the work started with Start, it is only enabled while that work is running
+type CancelButton struct {
+	widget.Button
+	mutex  sync.Mutex
+	cancel context.CancelFunc
+	// counts the started work, so finishing cancelled work doesn't disable the button for newer work
+	generation int
+}
+
+func NewCancelButton() *CancelButton {
+	w := &CancelButton{}
+	w.Text = "Cancel"
+	w.OnTapped = w.Cancel
+	w.ExtendBaseWidget(w)
+	w.Disable()
+	return w
+}
+
+// Cancel cancels the running work like tapping the button does
+func (w *CancelButton) Cancel() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.cancel != nil {
+		log.Info().Msg("cancelling running work")
+		w.cancel()
+	}
+}
+
+// Start cancels the work that is still running and returns the context for the new work.
+// The returned stop function has to be called once the work is done or cancelled to release the context.
+func (w *CancelButton) Start(parent context.Context) (context.Context, func()) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.cancel != nil {
+		w.cancel()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	w.cancel = cancel
+	w.generation++
+	generation := w.generation
+	w.Enable()
+	return ctx, func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		cancel()
+		if w.generation == generation {
+			w.cancel = nil
+			w.Disable()
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package ui
+
+import (
+	"slices"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/test"
+
+	"github.com/jkulzer/platform-router/models"
+
+	"github.com/jkulzer/osm"
+)
+
+func testService(id osm.RelationID, route, ref, to string) *osm.Relation {
+	tags := osm.Tags{{Key: "route", Value: route}, {Key: "to", Value: to}}
+	if ref != "" {
+		tags = append(tags, osm.Tag{Key: "ref", Value: ref})
+	}
+	return &osm.Relation{ID: id, Tags: tags}
+}
+
+// a selector with a subway platform, a train platform and a bus stop without a platform number
+func testSelector() *PlatformSelectorWidget {
+	return &PlatformSelectorWidget{items: models.PlatformList{
+		Platforms: []models.PlatformItem{
+			{ElementID: osm.WayID(1).ElementID(1), Services: []*osm.Relation{
+				testService(10, "subway", "U12", "Spandau"),
+				testService(11, "subway", "U2", "Pankow"),
+				testService(12, "subway", "", "Depot"),
+				testService(13, "subway", "U1", "Warschauer Straße"),
+				testService(14, "subway", "U2", "Alexanderplatz"),
+			}},
+			{ElementID: osm.RelationID(2).ElementID(1), Services: []*osm.Relation{
+				testService(20, "train", "RE1", "Magdeburg"),
+				testService(21, "ferry", "F10", "Kladow"),
+			}},
+			{ElementID: osm.WayID(3).ElementID(1), Services: []*osm.Relation{
+				testService(30, "bus", "100", "Zoo"),
+				testService(31, "horse", "", "Stable"),
+			}},
+		},
+		Ways: map[osm.WayID]*osm.Way{
+			1: {ID: 1, Tags: osm.Tags{{Key: "ref", Value: "3"}}},
+			3: {ID: 3},
+		},
+		Relations: map[osm.RelationID]*osm.Relation{
+			2: {ID: 2, Tags: osm.Tags{{Key: "ref", Value: "7"}}},
+		},
+	}}
+}
+
+func entryServices(entries []serviceEntry) []osm.RelationID {
+	var ids []osm.RelationID
+	for _, entry := range entries {
+		ids = append(ids, entry.service.ID)
+	}
+	return ids
+}
+
+func TestServiceEntries(t *testing.T) {
+	entries := testSelector().serviceEntries()
+	// by mode, then line with U1 < U2 < U12 and services without a ref last, then destination
+	expected := []osm.RelationID{20, 13, 14, 11, 10, 12, 30, 21, 31}
+	if ids := entryServices(entries); !slices.Equal(ids, expected) {
+		t.Errorf("expected the services %v, got %v", expected, ids)
+	}
+	expectedModes := []string{"Train", "Subway", "Bus", "Ferry", otherServicesName}
+	if modes := entryModes(entries); !slices.Equal(modes, expectedModes) {
+		t.Errorf("expected the modes %v, got %v", expectedModes, modes)
+	}
+	for _, entry := range entries {
+		if entry.service.ID == 20 && entry.platformNumber != "7" {
+			t.Errorf("expected the platform number of the relation, got %q", entry.platformNumber)
+		}
+		if entry.service.ID == 30 && entry.platformNumber != "" {
+			t.Errorf("expected no platform number, got %q", entry.platformNumber)
+		}
+	}
+}
+
+func TestServiceEntryMatches(t *testing.T) {
+	entry := serviceEntry{service: testService(1, "subway", "U2", "Pankow"), platformNumber: "3"}
+	tests := []struct {
+		filter   string
+		expected bool
+	}{
+		{"", true},
+		{"  ", true},
+		{"u2", true},
+		{"pank", true},
+		{" Pankow ", true},
+		{"3", true},
+		{"U1", false},
+		{"Spandau", false},
+	}
+	for _, test := range tests {
+		if result := entry.matches(test.filter); result != test.expected {
+			t.Errorf("expected %v for the filter %q, got %v", test.expected, test.filter, result)
+		}
+	}
+}
+
+// the texts of the list, headlines and the service lines
+func listTexts(list *fyne.Container) []string {
+	var texts []string
+	for _, object := range list.Objects {
+		if row, ok := object.(*fyne.Container); ok {
+			object = row.Objects[0]
+		}
+		if text, ok := object.(*canvas.Text); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return texts
+}
+
+func TestShowServices(t *testing.T) {
+	test.NewApp()
+	w := testSelector()
+	entries := w.serviceEntries()
+	tests := []struct {
+		name        string
+		filter      string
+		chosenModes map[string]bool
+		expected    []string
+	}{
+		{"bus and ferry", "", map[string]bool{"Bus": true, "Ferry": true}, []string{
+			"Bus:", "100 to Zoo",
+			"Ferry:", "F10 to Kladow on platform 7",
+		}},
+		{"filtered subway", "u2", map[string]bool{"Subway": true}, []string{
+			"Subway:", "U2 to Alexanderplatz on platform 3", "U2 to Pankow on platform 3",
+		}},
+		{"filter without chosen modes", "platz", map[string]bool{}, []string{
+			"Subway:", "U2 to Alexanderplatz on platform 3",
+		}},
+		{"nothing chosen matches", "RE1", map[string]bool{"Subway": true}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			list := container.NewVBox()
+			w.showServices(list, entries, test.filter, test.chosenModes)
+			if texts := listTexts(list); !slices.Equal(texts, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, texts)
+			}
+			if test.expected == nil && len(list.Objects) != 1 {
+				t.Errorf("expected only the notice that nothing matches, got %d objects", len(list.Objects))
+			}
+		})
+	}
+}